@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	_ "github.com/matheuscscp/splitwiser/cmd"
 	"github.com/matheuscscp/splitwiser/internal/bot"
@@ -12,7 +14,13 @@ import (
 )
 
 func main() {
-	if err := bot.Run(context.Background(), models.Matheus); err != nil {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s MEMBER_CODE\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	user := models.ReceiptItemOwner(os.Args[1])
+	if err := bot.Run(context.Background(), user); err != nil {
 		logrus.Fatalf("error running bot: %v", err)
 	}
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+
+	"github.com/matheuscscp/splitwiser/internal/evalreceipts"
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+	_ "github.com/matheuscscp/splitwiser/logging"
+	"github.com/matheuscscp/splitwiser/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var opts evalreceipts.Options
+	var currency string
+	flag.StringVar(&opts.Dir, "dir", "", "directory of receipt images and expected JSON receipts")
+	flag.StringVar(&opts.Extractor.Provider, "provider", openaipkg.ProviderOpenAI, "extractor provider: openai, openai-compatible or fake")
+	flag.StringVar(&opts.Extractor.BaseURL, "base-url", "", "base URL of the openai-compatible provider")
+	flag.StringVar(&opts.Extractor.Model, "model", openaipkg.DefaultModel, "extraction model")
+	flag.IntVar(&opts.Extractor.MaxTokens, "max-tokens", openaipkg.DefaultMaxTokens, "maximum tokens of each reply")
+	flag.StringVar(&opts.PromptPath, "prompt", "", "prompt template, the embedded one if empty")
+	flag.StringVar(&currency, "currency", "", "most likely currency of the receipts")
+	flag.StringVar(&opts.Prompt.Language, "language", "", "usual language of the receipts")
+	flag.StringVar(&opts.Replay, "replay", "", "replay the replies recorded in this directory instead of calling the extractor")
+	flag.StringVar(&opts.Record, "record", "", "record the replies of the extractor in this directory")
+	flag.Parse()
+	if opts.Dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts.Extractor.Token = os.Getenv("OPENAI_TOKEN")
+	opts.Prompt.Currency = models.Currency(currency)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if err := evalreceipts.Run(ctx, opts, os.Stdout); err != nil {
+		logrus.Fatalf("error evaluating receipt extraction: %v", err)
+	}
+}
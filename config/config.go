@@ -1,14 +1,24 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/matheuscscp/splitwiser/models"
 
 	"gopkg.in/yaml.v3"
 )
 
+var (
+	// member codes are combined with '+' and followed by '=' or '%' in the
+	// weights and quantity splits, so only letters and digits are allowed
+	regexMemberCode = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
 type (
 	// Bot ...
 	Bot struct {
@@ -19,26 +29,172 @@ type (
 			Token  string `yaml:"token"`
 			ChatID int64  `yaml:"chatID"`
 		} `yaml:"telegram"`
-		Splitwise        Splitwise `yaml:"splitwise"`
-		CheckpointBucket string    `yaml:"checkpointBucket"`
+		Extractor        Extractor   `yaml:"extractor"`
+		OCR              OCR         `yaml:"ocr"`
+		Splitwise        Splitwise   `yaml:"splitwise"`
+		Members          Members     `yaml:"members"`
+		Currencies       Currencies  `yaml:"currencies"`
+		Importers        Importers   `yaml:"importers"`
+		CheckpointBucket string      `yaml:"checkpointBucket"`
+		Storage          Storage     `yaml:"storage"`
+		Cache            Cache       `yaml:"cache"`
+		Usage            Usage       `yaml:"usage"`
+		Suggestions      Suggestions `yaml:"suggestions"`
+		Rules            Rules       `yaml:"rules"`
+	}
+
+	// Storage configures where the checkpoint and the other state of the
+	// bot, like the cache and the usage, are stored.
+	Storage struct {
+		// Provider is "bucket", the default, which stores the state in the
+		// checkpoint bucket, or "file", which stores it in Dir.
+		Provider string `yaml:"provider"`
+		Dir      string `yaml:"dir"`
+	}
+
+	// Rules configures the rules assigning items to owners by name.
+	Rules struct {
+		// Disabled keeps the rules added with /rules only while the bot runs.
+		Disabled bool `yaml:"disabled"`
+		// Rules are applied before the ones added with /rules.
+		Rules models.Rules `yaml:"rules"`
+	}
+
+	// Suggestions configures the owners suggested from previous receipts.
+	Suggestions struct {
+		// Disabled stores no history of owners and suggests nothing.
+		Disabled bool `yaml:"disabled"`
+		// MinConfidence is the least confidence of a suggestion, between 0
+		// and 1. See MinSuggestionConfidence.
+		MinConfidence float64 `yaml:"minConfidence"`
+	}
+
+	// Usage configures the accounting of the tokens used by models.
+	Usage struct {
+		// Disabled stores no monthly totals.
+		Disabled bool `yaml:"disabled"`
+		// Currency is the currency of Prices and MonthlyBudget, USD if empty.
+		Currency models.Currency `yaml:"currency"`
+		// Prices are the prices of a million tokens by model, the default
+		// prices of the usage service if empty.
+		Prices map[string]TokenPrice `yaml:"prices"`
+		// MonthlyBudget switches extraction to the fallback path once the
+		// costs of the month exceed it. Zero means no budget.
+		MonthlyBudget float64 `yaml:"monthlyBudget"`
+	}
+
+	// TokenPrice is the price of a million tokens of a model.
+	TokenPrice struct {
+		Prompt     float64 `yaml:"prompt"`
+		Completion float64 `yaml:"completion"`
+	}
+
+	// Cache configures the cache of receipts read from images.
+	Cache struct {
+		// Disabled reads every receipt again.
+		Disabled bool `yaml:"disabled"`
+	}
+
+	// Extractor configures the model extracting receipts from images.
+	Extractor struct {
+		// Provider is "openai", the default, "openai-compatible" or "fake".
+		Provider string `yaml:"provider"`
+		// BaseURL is the API of the "openai-compatible" provider. The
+		// openai token is sent to it, if any.
+		BaseURL   string `yaml:"baseURL"`
+		Model     string `yaml:"model"`
+		MaxTokens int    `yaml:"maxTokens"`
+		// Prompt is a text/template file overriding the embedded prompt.
+		Prompt string `yaml:"prompt"`
+		// Language is the usual language of the receipts, like "German".
+		Language string `yaml:"language"`
+	}
+
+	// OCR configures reading receipts offline with Tesseract.
+	OCR struct {
+		// Mode is OCRDisabled, the default, OCRFallback or OCRPrimary.
+		Mode string `yaml:"mode"`
+		// Binary is the tesseract-compatible binary.
+		Binary string `yaml:"binary"`
+		// Languages are Tesseract language codes like "eng+deu".
+		Languages string `yaml:"languages"`
 	}
 
 	// StartBot ...
 	StartBot struct {
-		Password    string `yaml:"password"`
-		ProjectID   string `yaml:"projectID"`
-		TopicID     string `yaml:"topicID"`
-		JWTSecretID string `yaml:"jwtSecretID"`
-		JWTSecret   []byte `yaml:"-"`
+		Password    string  `yaml:"password"`
+		ProjectID   string  `yaml:"projectID"`
+		TopicID     string  `yaml:"topicID"`
+		JWTSecretID string  `yaml:"jwtSecretID"`
+		JWTSecret   []byte  `yaml:"-"`
+		Members     Members `yaml:"members"`
 	}
 
 	// Splitwise ...
 	Splitwise struct {
-		Token     string `yaml:"token"`
-		GroupID   int64  `yaml:"groupID"`
-		AnaID     int64  `yaml:"anaID"`
-		MatheusID int64  `yaml:"matheusID"`
+		Token   string `yaml:"token"`
+		GroupID int64  `yaml:"groupID"`
+		// TimeZone is the IANA time zone of the receipt dates, like
+		// "Europe/Berlin". Empty means the local time zone.
+		TimeZone string `yaml:"timeZone"`
 	}
+
+	// Currencies configures the currencies of receipts and expenses.
+	Currencies struct {
+		// Default is the currency of receipts where none was detected.
+		Default models.Currency `yaml:"default"`
+		// Splitwise is the currency expenses are converted to before being
+		// created on Splitwise. Empty means no conversion.
+		Splitwise models.Currency `yaml:"splitwise"`
+		Rates     struct {
+			Provider string `yaml:"provider"`
+			File     string `yaml:"file"`
+		} `yaml:"rates"`
+	}
+
+	// Importers configures the importers of receipts sent as documents.
+	Importers struct {
+		CSV struct {
+			// Delimiter is detected from the header row if empty.
+			Delimiter string `yaml:"delimiter"`
+			// Currency is detected from the file if empty.
+			Currency models.Currency `yaml:"currency"`
+			// Columns are the header names of the item fields.
+			Columns struct {
+				Name      string `yaml:"name"`
+				Price     string `yaml:"price"`
+				Quantity  string `yaml:"quantity"`
+				UnitPrice string `yaml:"unitPrice"`
+			} `yaml:"columns"`
+		} `yaml:"csv"`
+		// PDFTextExtractor is the pdftotext-compatible binary extracting the
+		// text of PDF files.
+		PDFTextExtractor string `yaml:"pdfTextExtractor"`
+		// PDFRasterizer is the pdftoppm-compatible binary rendering the
+		// pages of PDF files without text for OpenAI.
+		PDFRasterizer string `yaml:"pdfRasterizer"`
+	}
+
+	// Members is the list of people sharing receipts.
+	Members []Member
+
+	// Member is a person sharing receipts.
+	Member struct {
+		Name             string `yaml:"name"`
+		Code             string `yaml:"code"`
+		TelegramUserName string `yaml:"telegramUserName"`
+		SplitwiseUserID  int64  `yaml:"splitwiseUserID"`
+	}
+)
+
+const (
+	// OCRDisabled never reads receipts with OCR.
+	OCRDisabled = "disabled"
+	// OCRFallback reads receipts with OCR when the extraction model fails.
+	OCRFallback = "fallback"
+	// OCRPrimary reads receipts with OCR first, and uses the extraction
+	// model only when OCR finds no items.
+	OCRPrimary = "primary"
 )
 
 // Load ...
@@ -57,10 +213,158 @@ func Load(conf interface{}) error {
 	return nil
 }
 
-// GetUserID ...
-func (s *Splitwise) GetUserID(user models.ReceiptItemOwner) int64 {
-	if user == models.Matheus {
-		return s.MatheusID
+// Validate checks that there is at least one member and that the member
+// codes are unique, non-empty, made of letters and digits and do not collide
+// with the reserved codes.
+func (m Members) Validate(reservedCodes ...string) error {
+	if len(m) == 0 {
+		return errors.New("at least one member must be configured")
+	}
+	seen := make(map[string]bool)
+	for _, code := range reservedCodes {
+		seen[strings.ToLower(code)] = true
+	}
+	for _, member := range m {
+		code := strings.ToLower(member.Code)
+		if code == "" {
+			return fmt.Errorf("member '%s' has an empty code", member.Name)
+		}
+		if !regexMemberCode.MatchString(code) {
+			return fmt.Errorf("member code '%s' must contain only letters and digits", member.Code)
+		}
+		if seen[code] {
+			return fmt.Errorf("member code '%s' is duplicated or reserved", member.Code)
+		}
+		seen[code] = true
+	}
+	return nil
+}
+
+// Owners returns the receipt item owners corresponding to the members.
+func (m Members) Owners() []models.ReceiptItemOwner {
+	owners := make([]models.ReceiptItemOwner, len(m))
+	for i, member := range m {
+		owners[i] = member.Owner()
+	}
+	return owners
+}
+
+// Get returns the member with the given receipt item owner code.
+func (m Members) Get(owner models.ReceiptItemOwner) (*Member, bool) {
+	for i := range m {
+		if m[i].Owner() == owner {
+			return &m[i], true
+		}
+	}
+	return nil, false
+}
+
+// FindByTelegramUserName returns the member with the given Telegram user name.
+func (m Members) FindByTelegramUserName(userName string) (*Member, bool) {
+	for i := range m {
+		if m[i].TelegramUserName != "" && m[i].TelegramUserName == userName {
+			return &m[i], true
+		}
+	}
+	return nil, false
+}
+
+// Name returns a human-readable name for the given receipt item owner.
+func (m Members) Name(owner models.ReceiptItemOwner) string {
+	if member, ok := m.Get(owner); ok {
+		return member.Name
+	}
+	if owner == models.Shared {
+		return "Shared"
+	}
+	if subset := owner.Members(m.Owners()); len(subset) > 1 {
+		names := make([]string, len(subset))
+		for i, o := range subset {
+			names[i] = m.Name(o)
+		}
+		return strings.Join(names, " + ")
+	}
+	return string(owner)
+}
+
+// Owner ...
+func (m *Member) Owner() models.ReceiptItemOwner {
+	return models.ReceiptItemOwner(strings.ToLower(m.Code))
+}
+
+// DefaultCurrency returns the configured default currency, or
+// models.DefaultCurrency if none was configured.
+func (c *Currencies) DefaultCurrency() models.Currency {
+	if cur, ok := models.ParseCurrency(string(c.Default)); ok {
+		return cur
+	}
+	return models.DefaultCurrency
+}
+
+// SplitwiseCurrency returns the currency expenses must be converted to
+// before being created on Splitwise, if any.
+func (c *Currencies) SplitwiseCurrency() (models.Currency, bool) {
+	return models.ParseCurrency(string(c.Splitwise))
+}
+
+// Location returns the time zone of the receipt dates.
+func (s *Splitwise) Location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("error loading time zone '%s': %w", s.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// ReceiptImporters returns the registry of receipt importers.
+func (i *Importers) ReceiptImporters() models.ReceiptImporters {
+	csv := &models.CSVImporter{
+		Columns: models.CSVColumns{
+			Name:      i.CSV.Columns.Name,
+			Price:     i.CSV.Columns.Price,
+			Quantity:  i.CSV.Columns.Quantity,
+			UnitPrice: i.CSV.Columns.UnitPrice,
+		},
+		Currency: i.CSV.Currency,
+	}
+	if i.CSV.Delimiter == `\t` {
+		csv.Delimiter = '\t'
+	} else if r := []rune(i.CSV.Delimiter); len(r) > 0 {
+		csv.Delimiter = r[0]
+	}
+	var importers models.ReceiptImporters
+	importers.Register(csv)
+	importers.Register(models.JSONImporter{})
+	importers.Register(models.PDFImporter{TextExtractor: i.PDFTextExtractor})
+	return importers
+}
+
+// Validate checks the OCR mode.
+func (o *OCR) Validate() error {
+	switch o.Mode {
+	case "", OCRDisabled, OCRFallback, OCRPrimary:
+		return nil
+	default:
+		return fmt.Errorf("unknown OCR mode '%s'", o.Mode)
+	}
+}
+
+// MinSuggestionConfidence returns the configured minimum confidence, or
+// 0.6 if none.
+func (s *Suggestions) MinSuggestionConfidence() float64 {
+	if s.MinConfidence <= 0 {
+		return 0.6
+	}
+	return s.MinConfidence
+}
+
+// PriceCurrency returns the currency of the prices and the budget.
+func (u *Usage) PriceCurrency() models.Currency {
+	if cur, ok := models.ParseCurrency(string(u.Currency)); ok {
+		return cur
 	}
-	return s.AnaID
+	return "USD"
 }
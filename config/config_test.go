@@ -0,0 +1,39 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembersValidate(t *testing.T) {
+	for _, tt := range []struct {
+		code string
+		err  string
+	}{
+		{code: "m"},
+		{code: "M2"},
+		{code: "", err: "member 'Matheus' has an empty code"},
+		{code: "m m", err: "member code 'm m' must contain only letters and digits"},
+		{code: "a+m", err: "member code 'a+m' must contain only letters and digits"},
+		{code: "m=2", err: "member code 'm=2' must contain only letters and digits"},
+		{code: "m%", err: "member code 'm%' must contain only letters and digits"},
+		{code: "mé", err: "member code 'mé' must contain only letters and digits"},
+		{code: "a", err: "member code 'a' is duplicated or reserved"},
+		{code: "Y", err: "member code 'Y' is duplicated or reserved"},
+	} {
+		t.Run(tt.code, func(t *testing.T) {
+			members := config.Members{
+				{Name: "Ana", Code: "a"},
+				{Name: "Matheus", Code: tt.code},
+			}
+			err := members.Validate("s", "y")
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
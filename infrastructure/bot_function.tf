@@ -78,8 +78,12 @@ resource "google_secret_manager_secret_version" "bot-config" {
     "splitwise" : {
       "token" : data.google_secret_manager_secret_version.bot-splitwise-token.secret_data,
       "groupID" : tonumber(data.google_secret_manager_secret_version.bot-splitwise-group-id.secret_data),
-      "anaID" : tonumber(data.google_secret_manager_secret_version.bot-splitwise-ana-id.secret_data),
-      "matheusID" : tonumber(data.google_secret_manager_secret_version.bot-splitwise-matheus-id.secret_data),
+    },
+    "members" : local.members,
+    "currencies" : {
+      "default" : "EUR",
+      "splitwise" : "EUR",
+      "rates" : { "provider" : "frankfurter" },
     },
     "checkpointBucket" : google_storage_bucket.bot-checkpoint.name,
   })
@@ -101,14 +105,6 @@ data "google_secret_manager_secret_version" "bot-splitwise-group-id" {
   secret = "bot-splitwise-group-id"
 }
 
-data "google_secret_manager_secret_version" "bot-splitwise-ana-id" {
-  secret = "bot-splitwise-ana-id"
-}
-
-data "google_secret_manager_secret_version" "bot-splitwise-matheus-id" {
-  secret = "bot-splitwise-matheus-id"
-}
-
 data "google_secret_manager_secret_version" "bot-openai-token" {
   secret = "bot-openai-token"
 }
@@ -15,6 +15,11 @@ locals {
   config_file      = "/latest.yml"
   config_file_path = format("%s%s", local.config_path, local.config_file)
   storage_location = upper(var.region)
+  members          = yamldecode(data.google_secret_manager_secret_version.members.secret_data)
+}
+
+data "google_secret_manager_secret_version" "members" {
+  secret = "members"
 }
 
 resource "google_storage_bucket" "source-code" {
@@ -70,6 +70,7 @@ resource "google_secret_manager_secret_version" "start-bot-config" {
     "projectID" : var.project,
     "topicID" : google_pubsub_topic.start-bot.name,
     "jwtSecretID" : google_secret_manager_secret.start-bot-jwt-secret.id,
+    "members" : local.members,
   })
 }
 
@@ -2,13 +2,12 @@ package bot
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,27 +15,52 @@ import (
 	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
 	_ "github.com/matheuscscp/splitwiser/logging"
 	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/pkg/ocr"
+	"github.com/matheuscscp/splitwiser/pkg/pdf"
 	"github.com/matheuscscp/splitwiser/pkg/splitwise"
+	"github.com/matheuscscp/splitwiser/services/cache"
 	"github.com/matheuscscp/splitwiser/services/checkpoint"
+	"github.com/matheuscscp/splitwiser/services/objects"
+	"github.com/matheuscscp/splitwiser/services/rates"
+	"github.com/matheuscscp/splitwiser/services/rules"
+	"github.com/matheuscscp/splitwiser/services/suggestions"
+	"github.com/matheuscscp/splitwiser/services/usage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	openai "github.com/sashabaranov/go-openai"
 	"github.com/sirupsen/logrus"
 )
 
 type (
 	botClient struct {
 		conf           *config.Bot
-		openAI         *openai.Client
+		extractor      openaipkg.ReceiptExtractor
+		cache          cache.Service
+		usage          usage.Service
+		suggestions    suggestions.Service
+		ruleStore      rules.Service
 		telegramClient *tgbotapi.BotAPI
 		chatID         int64
+		importers      models.ReceiptImporters
+		location       *time.Location
 		user           models.ReceiptItemOwner
 		closed         bool
 		msgQueue       []string
 		updateChannel  tgbotapi.UpdatesChannel
+		// pendingUpdates were received while collecting an album.
+		pendingUpdates []tgbotapi.Update
 
 		// state
-		chatMode bool
+		chatMode     bool
+		receiptUsage usage.Totals
+		// addedRules are the rules added with /rules, applied after the
+		// ones of the config.
+		addedRules models.Rules
+		// menuMessageID is the message with the active keyboard, if any,
+		// and pressedMessageID the message of the last pressed button, if
+		// the last update was a button.
+		menuMessageID    int
+		menuKind         menuKind
+		pressedMessageID int
 	}
 
 	botState int
@@ -47,15 +71,47 @@ const (
 	botStateParsingReceiptInteractively
 	botStateWaitingForPayer
 	botStateWaitingForStore
+	botStateCollectingPages
+	botStateReviewingSuggestions
+	botStateNamingStore
 
 	botLongPollingTimeout = 60 * time.Second
 	botTimeout            = 540*time.Second - botLongPollingTimeout - 5*time.Second
 
+	// albumTimeout is how long to wait for the next photo of an album,
+	// which Telegram sends as separate messages.
+	albumTimeout = 3 * time.Second
+
+	usageMonthLayout = "2006-01"
+
 	notReceiptItem   = "n"
 	resetReceipt     = "r"
 	newPrice         = "p"
+	setWeights       = "w"
+	linkDiscount     = "l"
+	splitQuantity    = "q"
+	setCurrency      = "c"
+	overrideTotal    = "o"
 	delayDecision    = "d"
 	undoLastDecision = "u"
+	renameItem       = "e"
+	addItem          = "i"
+	splitLine        = "b"
+	deleteItem       = "x"
+
+	undoCommand    = "/undo"
+	redoCommand    = "/redo"
+	historyCommand = "/history"
+	// historyLength is the number of operations listed by /history.
+	historyLength = 10
+
+	itemsCommand  = "/items"
+	assignCommand = "/assign"
+	// assignRemaining selects the pending items in /assign.
+	assignRemaining = "rest"
+
+	useReceiptStore   = "y"
+	acceptSuggestions = "y"
 )
 
 var (
@@ -78,6 +134,9 @@ func (b *botClient) enqueue(format string, args ...interface{}) {
 
 func (b *botClient) send(format string, args ...interface{}) {
 	b.enqueue(format, args...)
+	if b.menuOpen() {
+		b.closeMenu()
+	}
 
 	fullText := strings.Join(b.msgQueue, "\n\n")
 	logrus.Infof("[%s] %s", b.account(), fullText)
@@ -90,228 +149,933 @@ func (b *botClient) send(format string, args ...interface{}) {
 	}
 }
 
-func (b *botClient) sendReceiptItem(item *models.ReceiptItem, lastModifiedReceiptItem int) {
-	var undo string
-	if lastModifiedReceiptItem >= 0 {
-		undo = fmt.Sprintf("\n%s - Undo last decision", undoLastDecision)
+func (b *botClient) members() []models.ReceiptItemOwner {
+	return b.conf.Members.Owners()
+}
+
+func (b *botClient) memberCodes() []string {
+	var codes []string
+	for _, member := range b.members() {
+		codes = append(codes, string(member))
+	}
+	return codes
+}
+
+func (b *botClient) sendReceiptItem(receipt *models.Receipt, receiptItem int) {
+	item := receipt.Items[receiptItem]
+	var itemOptions string
+	if item.Price < 0 {
+		itemOptions += fmt.Sprintf("\n%s <item_number> - Link this discount to the item it discounts", linkDiscount)
+	}
+	if item.Quantity > 1 {
+		itemOptions += fmt.Sprintf("\n%s %s - Split the units between owners", splitQuantity, b.exampleQuantitySplit(item.Quantity))
+	}
+	var owners []tgbotapi.InlineKeyboardButton
+	for _, member := range b.conf.Members {
+		owners = append(owners, button(member.Name, string(member.Owner())))
+	}
+	others := []tgbotapi.InlineKeyboardButton{
+		button("Shared", string(models.Shared)),
+		button("Whole receipt", string(models.WholeReceipt)),
+		button("Not an item", notReceiptItem),
+	}
+	actions := []tgbotapi.InlineKeyboardButton{button("Delay", delayDecision)}
+	if receipt.CanUndo() {
+		actions = append(actions, button("Undo", undoLastDecision))
 	}
-	b.send(`%s (%s)
+	if receipt.CanRedo() {
+		actions = append(actions, button("Redo", redoCommand))
+	}
+	actions = append(actions, button("Overview", itemsCommand), button("Delete line", deleteItem), button("Reset", resetReceipt))
+	var total []tgbotapi.InlineKeyboardButton
+	if !receipt.IsReconciled() {
+		total = append(total, button("Accept difference to printed total", overrideTotal))
+	}
+	b.sendMenu(menuOwner, keyboard(owners, others, actions, total), `%d. %s
 
-Please choose the owner:
-%s - Set owned by Ana
-%s - Set owned by Matheus
-%s - Set owned by both (shared)
-%s - Not a receipt item
-%s - Reset receipt
+Please choose the owner. Whole receipt spreads the item over the whole receipt proportionally (e.g. a discount on the total). You can also type:
+%s - Set shared by some members (combine codes with +)
+%s %s - Set split unevenly (weights or percentages)%s
 %s <new_price> - Set new price
-%s - Delay item decision%s`,
-		item.Name,
-		item.Price,
-		models.Ana,
-		models.Matheus,
-		models.Shared,
-		notReceiptItem,
-		resetReceipt,
+%s <new_name> - Rename item
+%s <price> [<name>] - Move part of the price to a new line
+%s <name> <price> - Add a missing line after this one
+%s <item_number> - Delete another line
+%s <code> - Set receipt currency (currently %s)
+%s <items> <owner> - Assign several items, see %s`,
+		receiptItem+1,
+		item.Format(receipt.Currency),
+		b.exampleSubset(),
+		setWeights,
+		b.exampleWeights(),
+		itemOptions,
 		newPrice,
-		delayDecision,
-		undo,
+		renameItem,
+		splitLine,
+		addItem,
+		deleteItem,
+		setCurrency,
+		receipt.Currency.OrDefault(),
+		assignCommand,
+		itemsCommand,
 	)
 }
 
-func (b *botClient) sendOwnerChoice(lastModifiedReceiptItem int) {
+func (b *botClient) exampleSubset() string {
+	codes := b.memberCodes()
+	if len(codes) > 2 {
+		codes = codes[:2]
+	}
+	return strings.Join(codes, "+")
+}
+
+func (b *botClient) exampleWeights() string {
+	codes := b.memberCodes()
+	if len(codes) < 2 {
+		return "<code>=<weight> ..."
+	}
+	return fmt.Sprintf("%s=2 %s=1", codes[0], codes[1])
+}
+
+func (b *botClient) exampleQuantitySplit(quantity int) string {
+	codes := b.memberCodes()
+	return fmt.Sprintf("%s=%d %s=1", codes[0], quantity-1, models.Shared)
+}
+
+// enqueueOwnerChoice queues the valid choices of an owner, to be sent with
+// the menu of the item.
+func (b *botClient) enqueueOwnerChoice(receipt *models.Receipt) {
 	var undo string
-	if lastModifiedReceiptItem >= 0 {
+	if receipt.CanUndo() {
 		undo = fmt.Sprintf(", %s", undoLastDecision)
 	}
-	b.send(
-		"Invalid choice. Choose one of {%s, %s, %s, %s, %s, %s, %s%s}.",
-		models.Ana, models.Matheus, models.Shared, notReceiptItem, resetReceipt, newPrice, delayDecision, undo,
+	b.enqueue(
+		"Invalid choice. Choose one of {%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s%s}.",
+		strings.Join(b.memberCodes(), ", "), models.Shared, b.exampleSubset(), setWeights, models.WholeReceipt, linkDiscount,
+		splitQuantity, notReceiptItem, resetReceipt, newPrice, renameItem, splitLine, addItem, deleteItem, setCurrency, delayDecision, undo,
 	)
 }
 
-func (b *botClient) sendPayerChoice(receipt models.Receipt) {
-	ownerTotals, total, totalWithDiscounts := receipt.ComputeTotals()
-	b.send(`Ana's total: %v
-Matheus' total: %v
-Shared total: %v
-Total: %v
-Total with discounts: %v
-
-Please choose the payer:
-%s - Ana
-%s - Matheus
-%s - Reset receipt`,
-		ownerTotals[models.Ana],
-		ownerTotals[models.Matheus],
-		ownerTotals[models.Shared],
-		total,
-		totalWithDiscounts,
-		models.Ana,
-		models.Matheus,
-		resetReceipt,
+// ownerName describes the owner of an item, including the codes that are
+// not members.
+func (b *botClient) ownerName(owner models.ReceiptItemOwner) string {
+	switch owner {
+	case notReceiptItem:
+		return "Not a receipt item"
+	case models.WholeReceipt:
+		return "Whole receipt"
+	default:
+		return b.conf.Members.Name(owner)
+	}
+}
+
+// suggestOwners pre-fills the owners of the pending items of the receipt
+// with the owners chosen for the same items in previous receipts.
+func (b *botClient) suggestOwners(ctx context.Context, receipt *models.Receipt) {
+	var items []int
+	var names []string
+	for i, item := range receipt.Items {
+		if receipt.IsPending(i) {
+			items = append(items, i)
+			names = append(names, item.Name)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	suggested, err := b.suggestions.Suggest(ctx, receipt.Store, names)
+	if err != nil {
+		b.enqueue("I had an unexpected error loading the owners of previous receipts: %v", err)
+		return
+	}
+	minConfidence := b.conf.Suggestions.MinSuggestionConfidence()
+	for j, suggestion := range suggested {
+		if suggestion == nil || suggestion.Confidence < minConfidence {
+			continue
+		}
+		owner := suggestion.Owner
+		if owner == notReceiptItem || owner == models.WholeReceipt || owner.IsValid(b.members()) {
+			receipt.SuggestItemOwner(items[j], owner, suggestion.Confidence)
+		}
+	}
+}
+
+func (b *botClient) sendSuggestions(receipt *models.Receipt) {
+	suggested := receipt.Suggested()
+	var items string
+	for _, i := range suggested {
+		item := receipt.Items[i]
+		items += fmt.Sprintf("%d. %s - %s (%.0f%%)\n", i+1, item.Format(receipt.Currency), b.ownerName(item.Owner), item.Confidence*100)
+	}
+	accept := []tgbotapi.InlineKeyboardButton{button("Accept all", acceptSuggestions)}
+	b.sendMenu(menuSuggestions, keyboard(accept), `I chose these owners from previous receipts (confidence in parentheses):
+
+%s
+Please accept all the suggestions, or type the numbers of the items whose owners you want to choose yourself (e.g. %d).`,
+		items, suggested[0]+1)
+}
+
+// enqueueOverview queues the numbered list of the items of the receipt with
+// their current owners, to be sent with the next prompt.
+func (b *botClient) enqueueOverview(receipt *models.Receipt) {
+	var items string
+	for i, item := range receipt.Items {
+		owner := "?"
+		switch {
+		case item.DiscountOf != nil:
+			owner = fmt.Sprintf("discount of %d", *item.DiscountOf+1)
+		case item.Confidence > 0:
+			owner = fmt.Sprintf("%s (suggested)", b.ownerName(item.Owner))
+		case item.Owner != "":
+			owner = b.ownerName(item.Owner)
+		}
+		items += fmt.Sprintf("%d. %s - %s\n", i+1, item.Format(receipt.Currency), owner)
+	}
+	b.enqueue(`%s
+Assign several items at once with %s <items> <owner>, where <items> is %s (the items marked with ?), item numbers and ranges like 3-10,12, or a pattern matching the names like milk.`,
+		items, assignCommand, assignRemaining)
+}
+
+// parseAssign parses the arguments of /assign, "<items> <owner>", and
+// returns the indexes of the selected items, their owner and a description
+// of the selection.
+func (b *botClient) parseAssign(args string, receipt *models.Receipt) ([]int, models.ReceiptItemOwner, string, error) {
+	args = strings.TrimSpace(args)
+	i := strings.LastIndexAny(args, " \t")
+	if i < 0 {
+		return nil, "", "", errors.New("missing items or owner")
+	}
+	selection, code := strings.TrimSpace(args[:i]), strings.ToLower(args[i+1:])
+	owner, ok := models.ParseReceiptItemOwner(code, b.members())
+	if !ok {
+		if code != notReceiptItem && code != string(models.WholeReceipt) {
+			return nil, "", "", fmt.Errorf("invalid owner '%s'", code)
+		}
+		owner = models.ReceiptItemOwner(code)
+	}
+	if strings.ToLower(selection) == assignRemaining {
+		return receipt.PendingItems(), owner, "the remaining items", nil
+	}
+	if strings.Trim(selection, "0123456789-, ") == "" {
+		items, err := models.ParseItemRanges(selection, receipt.Len())
+		return items, owner, fmt.Sprintf("items %s", selection), err
+	}
+	items, err := receipt.MatchItems(selection)
+	return items, owner, fmt.Sprintf("the items matching %s", selection), err
+}
+
+func (b *botClient) sendHistory(receipt *models.Receipt) {
+	if receipt == nil || receipt.History == nil || (!receipt.CanUndo() && !receipt.CanRedo()) {
+		b.send("There are no changes to this receipt yet.")
+		return
+	}
+	done := receipt.History.Done
+	var ops string
+	for i := len(done) - 1; i >= 0 && i >= len(done)-historyLength; i-- {
+		ops += fmt.Sprintf("%d. %s %s\n", i+1, done[i].Time.In(b.location).Format("15:04"), done[i].Description)
+	}
+	if ops != "" {
+		ops = fmt.Sprintf("Recent changes, latest first (%s to undo the latest):\n\n%s", undoCommand, ops)
+	}
+	if undone := receipt.History.Undone; len(undone) > 0 {
+		ops += fmt.Sprintf("\nUndone changes (%s to redo the latest):\n\n", redoCommand)
+		for i := len(undone) - 1; i >= 0 && i >= len(undone)-historyLength; i-- {
+			ops += fmt.Sprintf("- %s %s\n", undone[i].Time.In(b.location).Format("15:04"), undone[i].Description)
+		}
+	}
+	b.send("%s", strings.TrimSpace(ops))
+}
+
+// parseNewItem parses the name and the price of an item to be added, like
+// "Oat Milk 1.99".
+func parseNewItem(args string, currency models.Currency) (string, models.PriceInCents, bool) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return "", 0, false
+	}
+	price, ok := currency.ParsePrice(fields[len(fields)-1])
+	if !ok {
+		return "", 0, false
+	}
+	return strings.Join(fields[:len(fields)-1], " "), price, true
+}
+
+// parseSuggestionsReview parses the numbers of the suggested items to be
+// reviewed, separated by spaces or commas.
+func parseSuggestionsReview(text string, suggested []int) ([]int, bool) {
+	var items []int
+	for _, tok := range strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			return nil, false
+		}
+		found := false
+		for _, i := range suggested {
+			found = found || i == n-1
+		}
+		if !found {
+			return nil, false
+		}
+		items = append(items, n-1)
+	}
+	return items, len(items) > 0
+}
+
+// recordOwners records the owners chosen for the items of the receipt under
+// its store, the same key suggestOwners uses, so they can be suggested in
+// the next receipts of the store. Discounts follow their items and weights
+// are specific to each receipt, so these items are skipped.
+func (b *botClient) recordOwners(ctx context.Context, receipt *models.Receipt) {
+	var decisions []suggestions.Decision
+	for _, item := range receipt.Items {
+		if item.DiscountOf == nil && item.Weights == nil && item.Owner != "" {
+			decisions = append(decisions, suggestions.Decision{Name: item.Name, Owner: item.Owner})
+		}
+	}
+	if err := b.suggestions.Record(ctx, receipt.Store, decisions); err != nil {
+		b.enqueue("I had an unexpected error recording the owners of this receipt: %v", err)
+	}
+}
+
+// rules returns the rules of the config followed by the ones added with
+// /rules.
+func (b *botClient) rules() models.Rules {
+	return append(append(models.Rules(nil), b.conf.Rules.Rules...), b.addedRules...)
+}
+
+// loadRules loads the rules added with /rules, dropping the ones that are
+// no longer valid, e.g. because a member was removed.
+func (b *botClient) loadRules(ctx context.Context) {
+	stored, err := b.ruleStore.Load(ctx)
+	if err != nil {
+		b.enqueue("I had an unexpected error loading the rules: %v", err)
+		return
+	}
+	b.addedRules = nil
+	for _, rule := range stored {
+		if _, err := b.newRule(string(rule.Owner), rule.Pattern); err != nil {
+			b.enqueue("I'm ignoring the rule %s -> %s: %v.", rule.Pattern, rule.Owner, err)
+			continue
+		}
+		b.addedRules = append(b.addedRules, rule)
+	}
+}
+
+func (b *botClient) newRule(owner, pattern string) (models.Rule, error) {
+	return models.NewRule(owner, pattern, b.members(), notReceiptItem, models.WholeReceipt)
+}
+
+// applyRules assigns the pending items of the receipt with the rules and
+// reports which rule assigned each item.
+func (b *botClient) applyRules(receipt *models.Receipt) {
+	all := b.rules()
+	matches := receipt.ApplyRules(all)
+	if len(matches) == 0 {
+		return
+	}
+	var items string
+	for _, match := range matches {
+		rule := all[match.Rule]
+		items += fmt.Sprintf("%d. %s - %s (rule %d: %s)\n",
+			match.Item+1, receipt.Items[match.Item].Format(receipt.Currency), b.ownerName(rule.Owner), match.Rule+1, rule.Pattern)
+	}
+	b.enqueue("I assigned these items with rules:\n\n%s", strings.TrimSuffix(items, "\n"))
+}
+
+// handleRules handles the /rules command, whose arguments are "list" (the
+// default), "add <owner> <pattern>" or "rm <rule_number>".
+func (b *botClient) handleRules(ctx context.Context, args string) {
+	const rulesUsage = "Please enter /rules list, /rules add <owner> <pattern> or /rules rm <rule_number>."
+	cmd, args, _ := strings.Cut(strings.TrimSpace(args), " ")
+	switch cmd {
+	case "", "list":
+		all := b.rules()
+		if len(all) == 0 {
+			b.send("There are no rules. Add one with /rules add <owner> <pattern>, e.g. /rules add %s (?i)vegan|oat|tofu.", b.memberCodes()[0])
+			return
+		}
+		var list string
+		for i, rule := range all {
+			var origin string
+			if i < len(b.conf.Rules.Rules) {
+				origin = " (config)"
+			}
+			list += fmt.Sprintf("%d. %s - %s%s\n", i+1, rule.Pattern, b.ownerName(rule.Owner), origin)
+		}
+		b.send("The first rule matching the name of an item assigns it:\n\n%s", strings.TrimSuffix(list, "\n"))
+	case "add":
+		owner, pattern, _ := strings.Cut(strings.TrimSpace(args), " ")
+		rule, err := b.newRule(owner, pattern)
+		if err != nil {
+			b.send("I can't add this rule: %v. %s", err, rulesUsage)
+			return
+		}
+		added := append(append(models.Rules(nil), b.addedRules...), rule)
+		if err := b.ruleStore.Store(ctx, added); err != nil {
+			b.send("I had an unexpected error storing the rules: %v", err)
+			return
+		}
+		b.addedRules = added
+		b.send("Added rule %d: %s - %s. It applies from the next receipt on.", len(b.rules()), rule.Pattern, b.ownerName(rule.Owner))
+	case "rm":
+		n, err := strconv.Atoi(strings.TrimSpace(args))
+		if err != nil || n < 1 || n > len(b.rules()) {
+			b.send("I can't find this rule. %s", rulesUsage)
+			return
+		}
+		i := n - 1 - len(b.conf.Rules.Rules)
+		if i < 0 {
+			b.send("Rule %d is in the config, please remove it there.", n)
+			return
+		}
+		added := append(append(models.Rules(nil), b.addedRules[:i]...), b.addedRules[i+1:]...)
+		if err := b.ruleStore.Store(ctx, added); err != nil {
+			b.send("I had an unexpected error storing the rules: %v", err)
+			return
+		}
+		b.addedRules = added
+		b.send("Removed rule %d.", n)
+	default:
+		b.send(rulesUsage)
+	}
+}
+
+func (b *botClient) sendStoreChoice(receipt *models.Receipt) {
+	if receipt.Store == "" {
+		b.send("Please type in the name of the store.")
+		return
+	}
+	useStore := []tgbotapi.InlineKeyboardButton{button(receipt.Store, useReceiptStore)}
+	b.sendMenu(menuStore, keyboard(useStore), "Please type in the name of the store, or use \"%s\".", receipt.Store)
+}
+
+func (b *botClient) sendPayerChoice(receipt *models.Receipt) {
+	ownerTotals, total, totalWithDiscounts := receipt.ComputeTotals(b.members())
+	var totals string
+	var payers []tgbotapi.InlineKeyboardButton
+	for _, member := range b.conf.Members {
+		totals += fmt.Sprintf("%s's total: %s\n", member.Name, receipt.Format(ownerTotals[member.Owner()]))
+		payers = append(payers, button(member.Name, string(member.Owner())))
+	}
+	for _, owner := range receipt.Owners() {
+		if owner != models.Shared && owner.IsShared(b.members()) {
+			totals += fmt.Sprintf("%s total: %s\n", b.conf.Members.Name(owner), receipt.Format(ownerTotals[owner]))
+		}
+	}
+	if wholeReceiptTotal := ownerTotals[models.WholeReceipt]; wholeReceiptTotal != 0 {
+		totals += fmt.Sprintf("Spread over the whole receipt: %s\n", receipt.Format(wholeReceiptTotal))
+	}
+	var reconciliation string
+	actions := []tgbotapi.InlineKeyboardButton{button("Reset", resetReceipt)}
+	if report := b.reconciliationReport(receipt); report != "" {
+		reconciliation = fmt.Sprintf("\n%s\n", report)
+		actions = append(actions, button("Accept difference to printed total", overrideTotal))
+	}
+	b.sendMenu(menuPayer, keyboard(payers, actions), `%sShared total: %s
+Total: %s
+Total with discounts: %s
+%s
+Please choose the payer.`,
+		totals,
+		receipt.Format(ownerTotals[models.Shared]),
+		receipt.Format(total),
+		receipt.Format(totalWithDiscounts),
+		reconciliation,
 	)
 }
 
+// reconciliationReport describes the difference between the printed total
+// and the sum of the items, or returns an empty string if there is none.
+func (b *botClient) reconciliationReport(receipt *models.Receipt) string {
+	rec, ok := receipt.Reconcile()
+	if !ok || rec.Difference() == 0 || receipt.TotalOverridden {
+		return ""
+	}
+	itemList := func(items []int) string {
+		numbers := make([]string, len(items))
+		for i, item := range items {
+			numbers[i] = fmt.Sprintf("%d. %s", item+1, receipt.Items[item].Format(receipt.Currency))
+		}
+		return strings.Join(numbers, ", ")
+	}
+	lines := []string{fmt.Sprintf("The items add up to %s, but the printed total is %s (difference: %s).",
+		receipt.Format(rec.Computed), receipt.Format(rec.Printed), receipt.Format(rec.Difference()))}
+	for _, duplicates := range rec.Duplicates {
+		lines = append(lines, fmt.Sprintf("Repeated lines: %s", itemList(duplicates)))
+	}
+	if len(rec.UnpairedDiscounts) > 0 {
+		lines = append(lines, fmt.Sprintf("Discounts not linked to an item: %s", itemList(rec.UnpairedDiscounts)))
+	}
+	if len(rec.Matches) > 0 {
+		lines = append(lines, fmt.Sprintf("Lines costing exactly the difference: %s", itemList(rec.Matches)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *botClient) linkDiscounts(receipt *models.Receipt) {
+	linked := receipt.LinkDiscounts()
+	if len(linked) == 0 {
+		return
+	}
+	var lines []string
+	for _, discount := range linked {
+		item := *receipt.Items[discount].DiscountOf
+		lines = append(lines, fmt.Sprintf("%d. %s -> %d. %s", discount+1, receipt.Items[discount].Name, item+1, receipt.Items[item].Name))
+	}
+	b.enqueue(`I linked these discounts to the items they discount, they will follow the owners of these items:
+
+%s
+
+To undo a link, enter %s <discount_number> 0 while choosing owners.`, strings.Join(lines, "\n"), linkDiscount)
+}
+
+// parseLinkDiscount parses the arguments of the link discount command,
+// where the discount number defaults to the current item. A negative item
+// index means unlinking.
+func parseLinkDiscount(args string, curItem int) (discount, item int, ok bool) {
+	fields := strings.Fields(args)
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, 0, false
+		}
+		numbers[i] = n - 1
+	}
+	switch len(numbers) {
+	case 1:
+		return curItem, numbers[0], true
+	case 2:
+		return numbers[0], numbers[1], true
+	default:
+		return 0, 0, false
+	}
+}
+
 func (b *botClient) sendMoreReceipts() {
 	b.send("More receipts?")
 }
 
-func (bc *botClient) handlePhoto(ctx context.Context, message *tgbotapi.Message) models.Receipt {
-	bc.send("M'kay, I'm sending this image to OpenAI for processing...")
+// downloadFile downloads a file sent to the bot, sending any errors to the
+// chat.
+func (bc *botClient) downloadFile(fileID string) ([]byte, bool) {
 	fd, err := bc.telegramClient.GetFile(tgbotapi.FileConfig{
-		FileID: message.Photo[len(message.Photo)-1].FileID,
+		FileID: fileID,
 	})
 	if err != nil {
 		bc.send("I got this error trying to get a descriptor for the file you sent me:\n\n%v", err)
-		return nil
+		return nil, false
 	}
 	f, err := http.Get(fd.Link(bc.conf.Telegram.Token))
 	if err != nil {
 		bc.send("I got this error trying to get the file you sent me:\n\n%v", err)
-		return nil
+		return nil, false
 	}
 	defer f.Body.Close()
 	b, err := io.ReadAll(f.Body)
 	if err != nil {
 		bc.send("I got this error trying to download the file you sent me:\n\n%v", err)
+		return nil, false
+	}
+	return b, true
+}
+
+func (bc *botClient) handleDocument(ctx context.Context, message *tgbotapi.Message) *models.Receipt {
+	doc := message.Document
+	b, ok := bc.downloadFile(doc.FileID)
+	if !ok {
 		return nil
 	}
-	image := base64.StdEncoding.EncodeToString(b)
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role: openai.ChatMessageRoleUser,
-			MultiContent: []openai.ChatMessagePart{
-				{
-					Type: openai.ChatMessagePartTypeText,
-					Text: `Hi! I'm Matheus' Telegram Bot for parsing his domestic receipts.
-
-Matheus programmed me to ask for your help when he sends photographs of his receipts to me.
-
-Please find attached a base64-encoded photograph of a receipt that Matheus sent to me.
-
-I need you to parse the photo and return the items in the exact example JSON format below, because I'm
-not as smart as you and I need the items to be in this simple text format so my Go code can understand
-it easily.
-
-Please output only the items like in the example format below, and nothing else. Please don't write
-any greeting messages or anything like that, because that makes it harder for me to parse your
-results. Just return me a valid JSON array like the one below. Please do not include the backticks
-wrapper.
-
-If there are fees at the end of the receipt photo, please include these fees as items.
-Discounts should also be included and have negative prices.
-
-Finally, here goes the example JSON format:
-
-[
-	{"name":"Smoky BBQ wings","euro_cents":399},
-	{"name":"Smoky BBQ wings Discount","euro_cents":-399},
-	{"name":"PopChips BBQ 5pk","euro_cents":249},
-	{"name":"RedHen Chicken Dippe","euro_cents":155},
-	{"name":"Whole Milk 2L","euro_cents":209},
-	{"name":"Coca Cola Regular","euro_cents":620},
-	{"name":"Ready Salted Crisps","euro_cents":119},
-	{"name":"Hummus Chips","euro_cents":149},
-	{"name":"Vegan Ice Sticks Alm","euro_cents":299}
-]`,
-				},
-				{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: fmt.Sprintf("data:image/jpeg;base64,%s", image),
-					},
-				},
-			},
-		},
-	}
-	var lastMessageFromOpenAI openai.ChatCompletionMessage
-	var receipt models.Receipt
-	parsePhoto := func() error {
-		for i := 0; i < 3; i++ {
-			resp, err := bc.openAI.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-				MaxTokens: 4096,
-				Model:     openai.GPT4o,
-				Messages:  messages,
-			})
-			if err != nil {
-				bc.send("OpenAI replied an error:\n\n%v", err)
-				return err
-			}
-			lastMessageFromOpenAI = resp.Choices[0].Message
-			cleanedResp := openaipkg.CleanOpenAIJSONResponse(lastMessageFromOpenAI.Content)
-			if err := json.Unmarshal([]byte(cleanedResp), &receipt); err != nil {
-				bc.send(`OpenAI replied an invalid JSON. This is a dumb error, I'm just gonna retry for you.
+	if strings.HasPrefix(doc.MimeType, "image/") {
+		return bc.readReceipt(ctx, [][]byte{b})
+	}
+	receipt, err := bc.importers.Import(doc.FileName, doc.MimeType, b)
+	if errors.Is(err, models.ErrUnsupportedFile) {
+		bc.send("I can't read this kind of file, please send me a CSV, JSON or PDF file.")
+		return nil
+	}
+	if errors.Is(err, models.ErrNoTextLayer) {
+		bc.enqueue("This PDF has no text, so I'm reading images of its pages.")
+		pages, err := pdf.Rasterize(ctx, b, bc.conf.Importers.PDFRasterizer)
+		if err != nil {
+			bc.send("I got this error trying to render the pages of the PDF you sent me:\n\n%v", err)
+			return nil
+		}
+		return bc.readReceipt(ctx, pages)
+	}
+	if err != nil {
+		bc.send("I got this error trying to import the file you sent me:\n\n%v", err)
+		return nil
+	}
+	if receipt.Currency == "" {
+		receipt.Currency = bc.conf.Currencies.DefaultCurrency()
+	}
+	if receipt.Len() == 0 {
+		bc.send("I found no items in this file. Let's try again.")
+	} else {
+		bc.send("Let's parse the following receipt:\n\n%s", receipt)
+	}
+	return receipt
+}
 
-Error: %v
+func (bc *botClient) handlePhoto(ctx context.Context, message *tgbotapi.Message) *models.Receipt {
+	b, ok := bc.downloadFile(message.Photo[len(message.Photo)-1].FileID)
+	if !ok {
+		return nil
+	}
+	images := [][]byte{b}
+	if message.MediaGroupID != "" {
+		images = append(images, bc.collectAlbum(ctx, message.MediaGroupID)...)
+	}
+	return bc.readReceipt(ctx, images)
+}
 
-Content:
+// collectAlbum downloads the other photos of an album, which Telegram sends
+// as separate messages in quick succession. The other updates received in
+// the meantime are kept in pendingUpdates.
+func (bc *botClient) collectAlbum(ctx context.Context, mediaGroupID string) [][]byte {
+	var images [][]byte
+	timer := time.NewTimer(albumTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return images
+		case <-timer.C:
+			return images
+		case update := <-bc.updateChannel:
+			message := update.Message
+			if message == nil || message.MediaGroupID != mediaGroupID || len(message.Photo) == 0 || bc.shouldSkip(message) {
+				// handled after the album, see nextUpdate
+				bc.pendingUpdates = append(bc.pendingUpdates, update)
+				continue
+			}
+			if b, ok := bc.downloadFile(message.Photo[len(message.Photo)-1].FileID); ok {
+				images = append(images, b)
+			}
+			timer.Reset(albumTimeout)
+		}
+	}
+}
+
+// readReceipt reads the receipt in the given images, which are photos or
+// pages of a single receipt, with OpenAI and OCR as configured. The accepted
+// receipts are cached by the contents of the images, so sending the same
+// images again offers the cached receipt, see cacheReceipt.
+func (bc *botClient) readReceipt(ctx context.Context, images [][]byte) *models.Receipt {
+	key := cache.Key(images...)
+	var cached *models.Receipt
+	if err := bc.cache.Load(ctx, key, &cached); err == nil && cached.Len() > 0 {
+		confirm := []tgbotapi.InlineKeyboardButton{button("Use it", "y"), button("Read again", "n")}
+		bc.sendMenu(menuConfirm, keyboard(confirm), `I've read these images before. Here is the receipt I got from them:
 
 %s
 
-Cleaned Content:
+Use this receipt, or read the images again?`, cached)
+		text, ok := bc.nextText(ctx)
+		if !ok {
+			return nil
+		}
+		if tl := strings.ToLower(strings.TrimSpace(text)); tl == "y" || tl == "yes" {
+			// the cached receipt costs nothing, so no usage is reported for it
+			bc.receiptUsage = usage.Totals{}
+			cached.CacheKey = key
+			return cached
+		}
+	} else if err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+		logrus.Errorf("error loading cached receipt: %v", err)
+	}
+
+	receipt := bc.readReceiptUncached(ctx, images)
+	if receipt.Len() > 0 {
+		receipt.CacheKey = key
+		if err := bc.cache.Store(ctx, key, receipt); err != nil {
+			logrus.Errorf("error caching receipt: %v", err)
+		}
+	}
+	return receipt
+}
+
+// cacheReceipt replaces the receipt cached when it was read with the final
+// one, without history, so sending the same images again offers the fixed
+// items and their owners.
+func (bc *botClient) cacheReceipt(ctx context.Context, receipt *models.Receipt) {
+	if receipt.CacheKey == "" {
+		return
+	}
+	final, err := receipt.Clone()
+	if err != nil {
+		logrus.Errorf("error copying receipt to cache: %v", err)
+		return
+	}
+	if err := bc.cache.Store(ctx, receipt.CacheKey, final); err != nil {
+		logrus.Errorf("error caching receipt: %v", err)
+	}
+}
+
+func (bc *botClient) readReceiptUncached(ctx context.Context, images [][]byte) *models.Receipt {
+	if bc.conf.OCR.Mode == config.OCRPrimary {
+		if receipt := bc.ocrReceipt(ctx, images); receipt != nil {
+			return receipt
+		}
+	}
+	if budget, exceeded := bc.budgetExceeded(ctx); exceeded {
+		if bc.conf.OCR.Mode == config.OCRFallback {
+			bc.enqueue("The monthly OpenAI budget of %s is exceeded.", budget)
+			return bc.ocrReceipt(ctx, images)
+		}
+		bc.send("The monthly OpenAI budget of %s is exceeded, please type in the receipt.", budget)
+		return nil
+	}
+	receipt, err := bc.extractReceipt(ctx, images)
+	if err != nil && ctx.Err() == nil && bc.conf.OCR.Mode == config.OCRFallback {
+		return bc.ocrReceipt(ctx, images)
+	}
+	return receipt
+}
+
+// budgetExceeded tells whether the costs of the current month exceed the
+// monthly budget, if any, which is returned formatted.
+func (bc *botClient) budgetExceeded(ctx context.Context) (string, bool) {
+	budget := bc.conf.Usage.MonthlyBudget
+	if budget <= 0 {
+		return "", false
+	}
+	months, err := bc.usage.Months(ctx)
+	if err != nil {
+		logrus.Errorf("error loading usage: %v", err)
+		return "", false
+	}
+	current := time.Now().Format(usageMonthLayout)
+	for _, month := range months {
+		if month.Month == current {
+			formatted := fmt.Sprintf("%s %s", usage.FormatCost(usage.ParseCost(budget)), bc.conf.Usage.PriceCurrency())
+			return formatted, month.Cost >= usage.ParseCost(budget)
+		}
+	}
+	return "", false
+}
+
+// tokenPrices returns the configured prices, or usage.DefaultPrices if none
+// were configured.
+func (bc *botClient) tokenPrices() usage.Prices {
+	if len(bc.conf.Usage.Prices) == 0 {
+		return usage.DefaultPrices
+	}
+	prices := make(usage.Prices, len(bc.conf.Usage.Prices))
+	for model, price := range bc.conf.Usage.Prices {
+		prices[model] = usage.Price{Prompt: price.Prompt, Completion: price.Completion}
+	}
+	return prices
+}
+
+// recordUsage adds the usage of a request to the totals of the receipt and
+// of the current month.
+func (bc *botClient) recordUsage(ctx context.Context, u openaipkg.Usage) {
+	totals, ok := bc.tokenPrices().Cost(u.Model, u.PromptTokens, u.CompletionTokens)
+	if !ok {
+		logrus.Warnf("unknown price of model '%s', its cost is not accounted", u.Model)
+	}
+	bc.receiptUsage.Add(totals)
+	if err := bc.usage.Add(ctx, time.Now().Format(usageMonthLayout), totals); err != nil {
+		logrus.Errorf("error recording usage: %v", err)
+	}
+}
+
+// enqueueUsageReport enqueues the tokens used to read the current receipt,
+// if it was read by a model, and clears them. Accepted receipts are reported
+// with the expenses created for them.
+func (bc *botClient) enqueueUsageReport() {
+	u := bc.receiptUsage
+	if u.Requests == 0 {
+		return
+	}
+	bc.enqueue("Reading this receipt took %d request(s) and %d tokens, costing %s %s.",
+		u.Requests, u.PromptTokens+u.CompletionTokens, usage.FormatCost(u.Cost), bc.conf.Usage.PriceCurrency())
+	bc.receiptUsage = usage.Totals{}
+}
+
+func (bc *botClient) sendCosts(ctx context.Context) {
+	months, err := bc.usage.Months(ctx)
+	if err != nil {
+		bc.send("I had an error loading the costs: %v", err)
+		return
+	}
+	if len(months) == 0 {
+		bc.send("There are no costs recorded yet.")
+		return
+	}
+	currency := bc.conf.Usage.PriceCurrency()
+	var lines []string
+	for _, month := range months {
+		lines = append(lines, fmt.Sprintf("%s: %s %s (%d requests, %d tokens)",
+			month.Month, usage.FormatCost(month.Cost), currency, month.Requests, month.PromptTokens+month.CompletionTokens))
+	}
+	var budget string
+	if b := bc.conf.Usage.MonthlyBudget; b > 0 {
+		budget = fmt.Sprintf("\n\nMonthly budget: %s %s", usage.FormatCost(usage.ParseCost(b)), currency)
+	}
+	bc.send("OpenAI costs by month:\n\n%s%s", strings.Join(lines, "\n"), budget)
+}
+
+// pendingUpdate pops the oldest update received while collecting an album.
+func (bc *botClient) pendingUpdate() (tgbotapi.Update, bool) {
+	if len(bc.pendingUpdates) == 0 {
+		return tgbotapi.Update{}, false
+	}
+	update := bc.pendingUpdates[0]
+	bc.pendingUpdates = bc.pendingUpdates[1:]
+	return update, true
+}
+
+// nextUpdate returns the pending updates before the ones of the update
+// channel. It returns false once the channel is closed.
+func (bc *botClient) nextUpdate() (tgbotapi.Update, bool) {
+	if update, ok := bc.pendingUpdate(); ok {
+		return update, true
+	}
+	update, ok := <-bc.updateChannel
+	return update, ok
+}
 
-%s`, err, lastMessageFromOpenAI.Content, cleanedResp)
+// nextText waits for the next text message of the user.
+func (bc *botClient) nextText(ctx context.Context) (string, bool) {
+	for {
+		update, ok := bc.pendingUpdate()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return "", false
+			case update = <-bc.updateChannel:
+			}
+		}
+		message := bc.updateMessage(update)
+		if message == nil {
+			continue
+		}
+		if bc.isToggleChat(message) {
+			bc.handleToggleChat()
+			continue
+		}
+		if bc.shouldSkip(message) {
+			continue
+		}
+		return message.Text, true
+	}
+}
+
+// ocrReceipt reads the receipt in the given images offline with OCR. It
+// returns nil if no items were found.
+func (bc *botClient) ocrReceipt(ctx context.Context, images [][]byte) *models.Receipt {
+	bc.send("M'kay, I'm reading this receipt offline with OCR...")
+	text, err := ocr.Recognize(ctx, images, bc.conf.OCR.Binary, bc.conf.OCR.Languages)
+	if err != nil {
+		bc.send("I got this error trying to read the receipt with OCR:\n\n%v", err)
+		return nil
+	}
+	receipt, unparsed := models.ParseReceipt(text)
+	if receipt.Currency == "" {
+		receipt.Currency = bc.conf.Currencies.DefaultCurrency()
+	}
+	if receipt.Len() == 0 {
+		bc.send("I found no items reading the receipt with OCR.")
+		return nil
+	}
+	if len(unparsed) > 0 {
+		bc.enqueue("I couldn't understand these lines, please check if they are missing items:\n\n%s", strings.Join(unparsed, "\n"))
+	}
+	bc.send("Let's parse the following receipt:\n\n%s", receipt)
+	return receipt
+}
+
+// extractReceipt asks OpenAI for the receipt in the given images and lets
+// the user ask for changes. It returns an error if OpenAI failed, and no
+// receipt and no error if the user aborted the receipt.
+func (bc *botClient) extractReceipt(ctx context.Context, images [][]byte) (*models.Receipt, error) {
+	if len(images) > 1 {
+		bc.send("M'kay, I'm sending these %d images to OpenAI for processing...", len(images))
+	} else {
+		bc.send("M'kay, I'm sending this image to OpenAI for processing...")
+	}
+	req := &openaipkg.ExtractionRequest{Images: images}
+	var lastReply string
+	var receipt *models.Receipt
+	bc.receiptUsage = usage.Totals{}
+	parsePhoto := func() error {
+		for i := 0; i < 3; i++ {
+			extraction, err := bc.extractor.Extract(ctx, req)
+			if extraction != nil {
+				bc.recordUsage(ctx, extraction.Usage)
+			}
+			if errors.Is(err, openaipkg.ErrInvalidReply) {
+				bc.send("OpenAI replied a receipt with problems, I'm asking it to fix them:\n\n- %s",
+					strings.Join(extraction.Problems, "\n- "))
+				req.Turns = append(req.Turns, openaipkg.ExtractionTurn{
+					Reply:    extraction.Content,
+					FollowUp: extraction.Correction(),
+				})
 				continue
 			}
+			if err != nil {
+				bc.send("OpenAI replied an error:\n\n%v", err)
+				bc.enqueueUsageReport()
+				return err
+			}
+			lastReply = extraction.Content
+			receipt = extraction.Receipt
+			receipt.PromptVersion = extraction.PromptVersion
+			if _, ok := models.ParseCurrency(string(receipt.Currency)); !ok {
+				receipt.Currency = bc.conf.Currencies.DefaultCurrency()
+			}
+			receipt.CompletePrices()
 			return nil
 		}
-		const maxRetriesErr = "OpenAI replied an invalid JSON 3 times in a row, I'm giving up."
+		const maxRetriesErr = "OpenAI replied a receipt with problems 3 times in a row, I'm giving up."
 		bc.send(maxRetriesErr)
+		bc.enqueueUsageReport()
 		return errors.New(maxRetriesErr)
 	}
 	askIfResultIsEnough := func() {
-		bc.send(`Here are the items and prices from OpenAI:
+		confirm := []tgbotapi.InlineKeyboardButton{button("Continue", "y"), button("Abort", "n")}
+		bc.sendMenu(menuConfirm, keyboard(confirm), `Here are the items and prices from OpenAI:
 
 %s
 
 Check if OpenAI forgot any items that are part of the receipt. Fees and discounts should be included.
 
-To continue parsing this receipt, enter y/yes.
-
-To abort this receipt, enter n/no.
+Continue parsing this receipt, or abort it?
 
 To send a follow-up message to OpenAI asking for changes in this receipt, just type in a prompt in natural language.`,
 			receipt,
 		)
 	}
-	if parsePhoto() != nil {
-		return nil
+	if err := parsePhoto(); err != nil {
+		return nil, err
 	}
 	askIfResultIsEnough()
 
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case followup := <-bc.updateChannel:
-			if followup.Message == nil {
-				continue
-			}
-			if bc.isToggleChat(followup.Message) {
-				bc.handleToggleChat()
-				continue
-			}
-			if bc.shouldSkip(followup.Message) {
-				continue
-			}
-			msg := followup.Message.Text
-			switch tl := strings.ToLower(strings.TrimSpace(msg)); {
-			case tl == "y" || tl == "yes":
-				return receipt
-			case tl == "n" || tl == "no":
-				bc.sendMoreReceipts()
-				return nil
-			}
-			bc.send("M'kay, I'm forwarding this follow-up prompt to OpenAI...")
-			messages = append(messages, lastMessageFromOpenAI, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
-				Content: strings.TrimSpace(msg),
-			})
-			if parsePhoto() != nil {
-				return nil
-			}
-			askIfResultIsEnough()
+		msg, ok := bc.nextText(ctx)
+		if !ok {
+			return nil, ctx.Err()
 		}
+		switch tl := strings.ToLower(strings.TrimSpace(msg)); {
+		case tl == "y" || tl == "yes":
+			return receipt, nil
+		case tl == "n" || tl == "no":
+			bc.enqueueUsageReport()
+			bc.sendMoreReceipts()
+			return nil, nil
+		}
+		bc.send("M'kay, I'm forwarding this follow-up prompt to OpenAI...")
+		req.Turns = append(req.Turns, openaipkg.ExtractionTurn{
+			Reply:    lastReply,
+			FollowUp: strings.TrimSpace(msg),
+		})
+		if err := parsePhoto(); err != nil {
+			return nil, err
+		}
+		askIfResultIsEnough()
 	}
 }
 
@@ -319,15 +1083,19 @@ func (b *botClient) shouldSkip(message *tgbotapi.Message) bool {
 	return b.closed ||
 		b.chatMode ||
 		message.Chat.ID != b.chatID ||
-		userFromMessage(message) != b.user ||
+		b.userFromMessage(message) != b.user ||
 		regexCya.MatchString(message.Text)
 }
 
-func userFromMessage(message *tgbotapi.Message) models.ReceiptItemOwner {
-	if message.From.UserName == "matheuscscp" {
-		return models.Matheus
+func (b *botClient) userFromMessage(message *tgbotapi.Message) models.ReceiptItemOwner {
+	if message.From == nil {
+		return ""
+	}
+	member, ok := b.conf.Members.FindByTelegramUserName(message.From.UserName)
+	if !ok {
+		return ""
 	}
-	return models.Ana
+	return member.Owner()
 }
 
 func (b *botClient) isToggleChat(message *tgbotapi.Message) bool {
@@ -353,21 +1121,83 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 	if err := config.Load(&conf); err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
+	reservedCodes := []string{
+		string(models.Shared), string(models.WholeReceipt),
+		notReceiptItem, resetReceipt, newPrice, setWeights, linkDiscount, splitQuantity, setCurrency, overrideTotal, delayDecision, undoLastDecision,
+		renameItem, addItem, splitLine, deleteItem, useReceiptStore, acceptSuggestions,
+	}
+	if err := conf.Members.Validate(reservedCodes...); err != nil {
+		return fmt.Errorf("invalid members config: %w", err)
+	}
+	if err := conf.Rules.Rules.Validate(conf.Members.Owners(), notReceiptItem, models.WholeReceipt); err != nil {
+		return fmt.Errorf("invalid rules config: %w", err)
+	}
+	if err := conf.OCR.Validate(); err != nil {
+		return fmt.Errorf("invalid OCR config: %w", err)
+	}
+	location, err := conf.Splitwise.Location()
+	if err != nil {
+		return fmt.Errorf("invalid splitwise config: %w", err)
+	}
+	if _, ok := conf.Members.Get(user); !ok {
+		return fmt.Errorf("unknown user '%s'", user)
+	}
 
-	openAI := openai.NewClient(conf.OpenAI.Token)
+	memberNames := make([]string, len(conf.Members))
+	for i, member := range conf.Members {
+		memberNames[i] = member.Name
+	}
+	prompt, err := openaipkg.LoadPrompt(conf.Extractor.Prompt, openaipkg.PromptData{
+		Members:  memberNames,
+		Currency: conf.Currencies.DefaultCurrency(),
+		Language: conf.Extractor.Language,
+	})
+	if err != nil {
+		return fmt.Errorf("error loading extraction prompt: %w", err)
+	}
+	extractor, err := openaipkg.NewReceiptExtractor(openaipkg.ExtractorOptions{
+		Provider:  conf.Extractor.Provider,
+		Token:     conf.OpenAI.Token,
+		BaseURL:   conf.Extractor.BaseURL,
+		Model:     conf.Extractor.Model,
+		MaxTokens: conf.Extractor.MaxTokens,
+		Prompt:    prompt,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating receipt extractor: %w", err)
+	}
 
 	telegramClient, err := tgbotapi.NewBotAPI(conf.Telegram.Token)
 	if err != nil {
 		return fmt.Errorf("error creating Telegram Bot API client: %w", err)
 	}
 
-	splitwiseClient := splitwise.NewClient(&conf.Splitwise)
+	splitwiseClient := splitwise.NewClient(&conf.Splitwise, conf.Members)
 
-	checkpointService, err := checkpoint.NewService(ctx, conf.CheckpointBucket)
+	store, closeStore, err := objects.NewStore(ctx, conf.Storage.Provider, conf.CheckpointBucket, conf.Storage.Dir)
 	if err != nil {
-		return fmt.Errorf("error creating checkpoint service: %w", err)
+		return fmt.Errorf("error creating storage: %w", err)
+	}
+	defer closeStore()
+	// storeUnless returns the shared store, or fallback if the service is
+	// disabled
+	storeUnless := func(disabled bool, fallback objects.Store) objects.Store {
+		if disabled {
+			return fallback
+		}
+		return store
+	}
+
+	checkpointService := checkpoint.NewService(store)
+	cacheService := cache.NewService(storeUnless(conf.Cache.Disabled, objects.Disabled))
+	usageService := usage.NewService(storeUnless(conf.Usage.Disabled, objects.Disabled))
+	suggestionsService := suggestions.NewService(storeUnless(conf.Suggestions.Disabled, objects.Disabled))
+	ruleService := rules.NewService(storeUnless(conf.Rules.Disabled, objects.NewMemoryStore()))
+
+	ratesService, err := rates.NewService(conf.Currencies.Rates.Provider, conf.Currencies.Rates.File)
+	if err != nil {
+		return fmt.Errorf("error creating exchange rates service: %w", err)
 	}
-	defer checkpointService.Close()
 
 	updateConf := tgbotapi.NewUpdate(0 /*offset*/)
 	updateConf.Timeout = int(botLongPollingTimeout.Seconds())
@@ -375,14 +1205,21 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 
 	bot := &botClient{
 		conf:           &conf,
-		openAI:         openAI,
+		extractor:      extractor,
+		cache:          cacheService,
+		usage:          usageService,
+		suggestions:    suggestionsService,
+		ruleStore:      ruleService,
 		telegramClient: telegramClient,
 		chatID:         conf.Telegram.ChatID,
+		importers:      conf.Importers.ReceiptImporters(),
+		location:       location,
 		user:           user,
 		updateChannel:  updateChannel,
 	}
 
 	logrus.Infof("Authenticated on Telegram bot account %s", bot.account())
+	bot.loadRules(ctx)
 
 	// shutdown thread
 	go func() {
@@ -393,13 +1230,13 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 
 	// bot state
 	botState := botStateIdle
-	var receipt models.Receipt
+	var receipt *models.Receipt
+	var pages [][]byte
 	var payer models.ReceiptItemOwner
 	var nextReceiptItem int
-	lastModifiedReceiptItem := -1
 
 	// load checkpoint
-	bot.enqueue("Hi, %s.", user.Pretty())
+	bot.enqueue("Hi, %s.", conf.Members.Name(user))
 	if err := checkpointService.Load(ctx, &receipt); err != nil {
 		if !errors.Is(err, checkpoint.ErrCheckpointNotExist) {
 			bot.enqueue("I had an unexpected error loading the checkpoint: %v", err)
@@ -408,14 +1245,15 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 		receipt = nil
 	} else {
 		bot.enqueue("I found a previous receipt, let's finish it.")
-		for nextReceiptItem < receipt.Len() && receipt[nextReceiptItem].Owner != "" {
-			nextReceiptItem++
-		}
-		if nextReceiptItem == receipt.Len() {
+		nextReceiptItem = receipt.NextPendingItem(0)
+		if len(receipt.Suggested()) > 0 {
+			bot.sendSuggestions(receipt)
+			botState = botStateReviewingSuggestions
+		} else if !receipt.IsPending(nextReceiptItem) {
 			bot.sendPayerChoice(receipt)
 			botState = botStateWaitingForPayer
 		} else {
-			bot.sendReceiptItem(receipt[nextReceiptItem], lastModifiedReceiptItem)
+			bot.sendReceiptItem(receipt, nextReceiptItem)
 			botState = botStateParsingReceiptInteractively
 		}
 	}
@@ -426,10 +1264,28 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 		}
 	}
 
+	// snapshot clones the receipt before a change, see commit.
+	snapshot := func() *models.Receipt {
+		before, err := receipt.Clone()
+		if err != nil {
+			bot.enqueue("I had an unexpected error copying the receipt, the next change can't be undone: %v", err)
+		}
+		return before
+	}
+
+	// commit records a change of the receipt in its history, given the
+	// snapshot and the item being decided before the change, and stores the
+	// checkpoint.
+	commit := func(description string, before *models.Receipt, item int) {
+		if before != nil {
+			receipt.Record(description, item, before)
+		}
+		storeCheckpoint()
+	}
+
 	softResetState := func() {
 		payer = ""
 		nextReceiptItem = 0
-		lastModifiedReceiptItem = -1
 	}
 
 	resetState := func() {
@@ -441,23 +1297,157 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 
 		botState = botStateIdle
 		receipt = nil
+		pages = nil
+		bot.receiptUsage = usage.Totals{}
 		softResetState()
 
 		bot.sendMoreReceipts()
 	}
 
 	softResetOption := func() {
+		before, current := snapshot(), nextReceiptItem
 		bot.send("M'kay, let's go back to the beginning of this receipt:\n\n%s", receipt)
 		softResetState()
-		for _, item := range receipt {
-			item.Owner = ""
+		for _, item := range receipt.Items {
+			item.SetOwner("")
 		}
-		storeCheckpoint()
+		commit("Reset the receipt", before, current)
 		botState = botStateParsingReceiptInteractively
-		bot.sendReceiptItem(receipt[0], lastModifiedReceiptItem)
+		nextReceiptItem = receipt.NextPendingItem(0)
+		bot.sendReceiptItem(receipt, nextReceiptItem)
+	}
+
+	decideOwner := func(owner models.ReceiptItemOwner, weights models.ReceiptItemWeights, before *models.Receipt) {
+		item := nextReceiptItem
+		receipt.SetItemOwner(item, owner, weights)
+		nextReceiptItem = receipt.NextPendingItem(receipt.NextItem(item))
+		commit(fmt.Sprintf("Set the owner of item %d (%s) to %s", item+1, receipt.Items[item].Name, bot.ownerName(owner)), before, item)
+	}
+
+	// itemDeleted keeps the current item after the item at index i is
+	// deleted, or moves to the next pending one if it was the deleted item.
+	itemDeleted := func(i int) {
+		switch {
+		case nextReceiptItem == i:
+			nextReceiptItem = receipt.NextPendingItem(i % receipt.Len())
+		case nextReceiptItem > i:
+			nextReceiptItem--
+		}
+	}
+
+	reportReconciled := func() {
+		if rec, ok := receipt.Reconcile(); ok && rec.Difference() == 0 {
+			bot.enqueue("The items now add up to the printed total.")
+		}
+	}
+
+	askStoreName := func() {
+		bot.send("Please type in the name of the store, so I can suggest the owners chosen in its previous receipts.")
+		botState = botStateNamingStore
+	}
+
+	// askNextDecision asks to review the suggested owners, then for the
+	// owner of the next pending item, then for the payer. While the store is
+	// being named it asks for the name again, so commands like /items or
+	// /undo do not skip it.
+	askNextDecision := func() {
+		if botState == botStateNamingStore {
+			askStoreName()
+			return
+		}
+		nextReceiptItem = receipt.NextPendingItem(nextReceiptItem % receipt.Len())
+		switch {
+		case len(receipt.Suggested()) > 0:
+			bot.sendSuggestions(receipt)
+			botState = botStateReviewingSuggestions
+		case receipt.IsPending(nextReceiptItem):
+			bot.sendReceiptItem(receipt, nextReceiptItem)
+			botState = botStateParsingReceiptInteractively
+		default:
+			bot.sendPayerChoice(receipt)
+			botState = botStateWaitingForPayer
+		}
+	}
+
+	startReceipt := func() {
+		if receipt.Len() == 0 {
+			return
+		}
+		bot.linkDiscounts(receipt)
+		bot.applyRules(receipt)
+		if report := bot.reconciliationReport(receipt); report != "" {
+			bot.enqueue("%s\n\nPlease fix the prices while choosing the owners, or enter %s to accept the difference.", report, overrideTotal)
+		}
+		// owners are learned by store, so receipts without one, like typed
+		// ones, are named first
+		if receipt.Store == "" && !conf.Suggestions.Disabled {
+			storeCheckpoint()
+			askStoreName()
+			return
+		}
+		bot.suggestOwners(ctx, receipt)
+		storeCheckpoint()
+		nextReceiptItem = 0
+		askNextDecision()
+	}
+
+	// undoOrRedo undoes or redoes the last operation on the receipt and asks
+	// for the next decision from the item being decided before it.
+	undoOrRedo := func(command string) {
+		op, ok := receipt.Undo()
+		verb := "Undid"
+		if command == redoCommand {
+			op, ok = receipt.Redo()
+			verb = "Redid"
+		}
+		if !ok {
+			bot.send("There is nothing to %s.", strings.TrimPrefix(command, "/"))
+			return
+		}
+		bot.enqueue("%s: %s.", verb, op.Description)
+		storeCheckpoint()
+		payer = ""
+		nextReceiptItem = op.Item
+		askNextDecision()
+	}
+
+	// assign handles /assign, setting the owner of several items at once.
+	assign := func(args string) {
+		before := snapshot()
+		items, owner, selection, err := bot.parseAssign(args, receipt)
+		if err != nil {
+			bot.enqueueOverview(receipt)
+			bot.enqueue("I can't assign these items: %v.", err)
+			askNextDecision()
+			return
+		}
+		assigned := receipt.AssignItems(items, owner)
+		if len(assigned) == 0 {
+			bot.enqueue("There are no items to assign in %s.", selection)
+			askNextDecision()
+			return
+		}
+		bot.enqueue("Assigned %d items to %s.", len(assigned), bot.ownerName(owner))
+		commit(fmt.Sprintf("Assigned %s to %s", selection, bot.ownerName(owner)), before, nextReceiptItem)
+		payer = ""
+		askNextDecision()
 	}
 
 	createExpense := func(expenseType string, expense *models.Expense, storeName string) {
+		if to, ok := conf.Currencies.SplitwiseCurrency(); ok && to != expense.Currency {
+			rate, err := ratesService.Rate(ctx, expense.Currency, to)
+			if err != nil {
+				bot.enqueue("I had an unexpected error fetching the exchange rate, creating the %s expense in %s: %v", expenseType, expense.Currency, err)
+			} else {
+				converted := expense.Convert(to, rate)
+				bot.enqueue("Converted the %s expense from %s %s to %s %s (rate %s).",
+					expenseType,
+					expense.Currency.Format(expense.Cost), expense.Currency,
+					converted.Currency.Format(converted.Cost), converted.Currency,
+					rate.FloatString(6))
+				expense = converted
+			}
+		}
 		bot.send("Creating %s expense...", expenseType)
 		msg := splitwiseClient.CreateExpense(ctx, expense, storeName)
 		bot.enqueue(msg)
@@ -469,12 +1459,15 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 		createExpense("shared", expense, storeName)
 	}
 
-	for update := range updateChannel {
-		if update.Message == nil {
+	for {
+		update, ok := bot.nextUpdate()
+		if !ok {
+			break
+		}
+		message := bot.updateMessage(update)
+		if message == nil {
 			continue
 		}
-
-		message := update.Message
 		if bot.isToggleChat(message) {
 			bot.handleToggleChat()
 			continue
@@ -496,6 +1489,40 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 			bot.send("I'm up for %s.", time.Since(startTime))
 			continue
 		}
+		if message.Text == "/costs" {
+			bot.sendCosts(ctx)
+			continue
+		}
+		hasReceipt := botState != botStateIdle && botState != botStateCollectingPages
+		if hasReceipt && botState == botStateParsingReceiptInteractively &&
+			strings.TrimSpace(strings.ToLower(message.Text)) == undoLastDecision {
+			message.Text = undoCommand
+		}
+		if hasReceipt && (message.Text == undoCommand || message.Text == redoCommand) {
+			undoOrRedo(message.Text)
+			continue
+		}
+		if hasReceipt && message.Text == itemsCommand {
+			bot.enqueueOverview(receipt)
+			if botState == botStateWaitingForStore {
+				bot.sendStoreChoice(receipt)
+			} else {
+				askNextDecision()
+			}
+			continue
+		}
+		if hasReceipt && (message.Text == assignCommand || strings.HasPrefix(message.Text, assignCommand+" ")) {
+			assign(strings.TrimPrefix(message.Text, assignCommand))
+			continue
+		}
+		if message.Text == historyCommand {
+			bot.sendHistory(receipt)
+			continue
+		}
+		if message.Text == "/rules" || strings.HasPrefix(message.Text, "/rules ") {
+			bot.handleRules(ctx, strings.TrimPrefix(message.Text, "/rules"))
+			continue
+		}
 		if message.Text == "/finish" {
 			cancel()
 			continue
@@ -503,82 +1530,267 @@ func Run(ctx context.Context, user models.ReceiptItemOwner) error {
 
 		switch botState {
 		case botStateIdle:
+			if message.Text == "/pages" {
+				bot.send("Send me the photos of the pages of the receipt in order, then /done.")
+				botState = botStateCollectingPages
+				continue
+			}
 			if len(message.Photo) > 0 {
 				receipt = bot.handlePhoto(ctx, message)
+			} else if message.Document != nil {
+				receipt = bot.handleDocument(ctx, message)
 			} else {
-				receipt = models.ParseReceipt(message.Text)
+				var unparsed []string
+				receipt, unparsed = models.ParseReceipt(message.Text)
+				if receipt.Currency == "" {
+					receipt.Currency = conf.Currencies.DefaultCurrency()
+				}
 				if receipt.Len() == 0 {
 					bot.send("I can't understand that. Let's try again.")
 				} else {
+					if len(unparsed) > 0 {
+						bot.enqueue("I couldn't understand these lines, please check if they are missing items:\n\n%s", strings.Join(unparsed, "\n"))
+					}
 					bot.send("Let's parse the following receipt:\n\n%s", receipt)
 				}
 			}
-			if receipt.Len() > 0 {
-				storeCheckpoint()
-				bot.sendReceiptItem(receipt[0], lastModifiedReceiptItem)
-				botState = botStateParsingReceiptInteractively
+			startReceipt()
+		case botStateCollectingPages:
+			switch {
+			case len(message.Photo) > 0:
+				if b, ok := bot.downloadFile(message.Photo[len(message.Photo)-1].FileID); ok {
+					pages = append(pages, b)
+					bot.send("Got page %d. Send me the next one, or /done.", len(pages))
+				}
+			case message.Text == "/done" && len(pages) > 0:
+				receipt = bot.readReceipt(ctx, pages)
+				pages = nil
+				botState = botStateIdle
+				startReceipt()
+			default:
+				bot.send("Please send me a photo of the next page, or /done when there are no more pages.")
+			}
+		case botStateNamingStore:
+			storeName := strings.TrimSpace(message.Text)
+			if storeName == "" {
+				bot.send("Store name cannot be empty.")
+				break
 			}
+			receipt.Store = storeName
+			bot.suggestOwners(ctx, receipt)
+			storeCheckpoint()
+			nextReceiptItem = 0
+			askNextDecision()
+		case botStateReviewingSuggestions:
+			before := snapshot()
+			description := "Accepted the suggested owners"
+			text := strings.TrimSpace(strings.ToLower(message.Text))
+			if text != acceptSuggestions {
+				review, ok := parseSuggestionsReview(text, receipt.Suggested())
+				if !ok {
+					bot.enqueue("Invalid choice. Enter %s to accept all the suggestions, or the numbers of the suggested items you want to review.", acceptSuggestions)
+					bot.sendSuggestions(receipt)
+					continue
+				}
+				for _, i := range review {
+					receipt.SetItemOwner(i, "", nil)
+				}
+				description = fmt.Sprintf("Accepted the suggested owners except items %s", text)
+			}
+			receipt.AcceptSuggestions()
+			commit(description, before, nextReceiptItem)
+			nextReceiptItem = 0
+			askNextDecision()
 		case botStateParsingReceiptInteractively:
-			message.Text = strings.TrimSpace(strings.ToLower(message.Text))
+			before, current := snapshot(), nextReceiptItem
+			currentName := receipt.Items[current].Name
+			text := strings.TrimSpace(message.Text)
+			message.Text = strings.ToLower(text)
+			owner, isOwner := models.ParseReceiptItemOwner(message.Text, bot.members())
 			switch {
-			case message.Text == string(models.Ana) || message.Text == string(models.Matheus) || message.Text == string(models.Shared) || message.Text == notReceiptItem:
-				receipt[nextReceiptItem].Owner = models.ReceiptItemOwner(message.Text)
-				lastModifiedReceiptItem = nextReceiptItem
-				nextReceiptItem = receipt.NextItem(nextReceiptItem)
-				for receipt[nextReceiptItem].Owner != "" && nextReceiptItem != lastModifiedReceiptItem {
-					nextReceiptItem = receipt.NextItem(nextReceiptItem)
+			case isOwner || message.Text == notReceiptItem || message.Text == string(models.WholeReceipt):
+				if !isOwner {
+					owner = models.ReceiptItemOwner(message.Text)
+				}
+				decideOwner(owner, nil, before)
+			case strings.HasPrefix(message.Text, setWeights+" "):
+				owner, weights, err := models.ParseReceiptItemWeights(message.Text[len(setWeights+" "):], bot.members())
+				if err != nil {
+					bot.enqueue("I can't understand these weights: %v. Please try again.", err)
+					break
+				}
+				decideOwner(owner, weights, before)
+			case strings.HasPrefix(message.Text, linkDiscount+" "):
+				discount, item, ok := parseLinkDiscount(message.Text[len(linkDiscount+" "):], nextReceiptItem)
+				if !ok {
+					bot.enqueue("I can't understand that, please enter %s <item_number> or %s <discount_number> <item_number>.", linkDiscount, linkDiscount)
+					break
+				}
+				var err error
+				if item < 0 {
+					err = receipt.UnlinkDiscount(discount)
+				} else {
+					err = receipt.LinkDiscount(discount, item)
+				}
+				if err != nil {
+					bot.enqueue("I can't do that: %v.", err)
+					break
+				}
+				nextReceiptItem = receipt.NextPendingItem(nextReceiptItem)
+				if item < 0 {
+					commit(fmt.Sprintf("Unlinked discount %d", discount+1), before, current)
+				} else {
+					commit(fmt.Sprintf("Linked discount %d to item %d", discount+1, item+1), before, current)
+				}
+			case strings.HasPrefix(message.Text, splitQuantity+" "):
+				owners, quantities, err := models.ParseQuantitySplit(message.Text[len(splitQuantity+" "):], bot.members())
+				if err != nil {
+					bot.enqueue("I can't understand this split: %v. Please try again.", err)
+					break
 				}
-				storeCheckpoint()
+				if err := receipt.SplitItem(nextReceiptItem, owners, quantities); err != nil {
+					bot.enqueue("I can't split this item: %v.", err)
+					break
+				}
+				nextReceiptItem = receipt.NextPendingItem(nextReceiptItem)
+				commit(fmt.Sprintf("Split the units of item %d (%s)", current+1, currentName), before, current)
 			case message.Text == resetReceipt:
 				softResetOption()
 				continue
+			case strings.HasPrefix(message.Text, setCurrency+" "):
+				currency, ok := models.ParseCurrency(message.Text[len(setCurrency+" "):])
+				if !ok {
+					bot.enqueue("I can't understand that currency, please enter an ISO 4217 code like EUR or GBP.")
+					break
+				}
+				receipt.Currency = currency
+				bot.enqueue("The currency of this receipt is now %s.", currency)
+				commit(fmt.Sprintf("Set the currency to %s", currency), before, current)
 			case strings.HasPrefix(message.Text, newPrice+" "):
-				price, ok := models.ParsePriceInCents(message.Text[len(newPrice+" "):])
+				price, ok := receipt.Currency.OrDefault().ParsePrice(message.Text[len(newPrice+" "):])
 				if !ok {
-					bot.send("I can't understand that price, please try again.")
-					continue
+					bot.enqueue("I can't understand that price, please try again.")
+					break
 				}
-				receipt[nextReceiptItem].Price = price
-				storeCheckpoint()
-			case message.Text == delayDecision:
-				nextReceiptItem = receipt.NextItem(nextReceiptItem)
-				for receipt[nextReceiptItem].Owner != "" {
-					nextReceiptItem = receipt.NextItem(nextReceiptItem)
+				receipt.Items[nextReceiptItem].Price = price
+				reportReconciled()
+				commit(fmt.Sprintf("Set the price of item %d (%s) to %s", current+1, receipt.Items[current].Name, receipt.Format(price)), before, current)
+			case strings.HasPrefix(message.Text, renameItem+" "):
+				if err := receipt.RenameItem(nextReceiptItem, text[len(renameItem+" "):]); err != nil {
+					bot.enqueue("I can't rename this item: %v.", err)
+					break
+				}
+				commit(fmt.Sprintf("Renamed item %d from %s to %s", current+1, currentName, receipt.Items[current].Name), before, current)
+			case strings.HasPrefix(message.Text, addItem+" "):
+				name, price, ok := parseNewItem(text[len(addItem+" "):], receipt.Currency.OrDefault())
+				if !ok {
+					bot.enqueue("I can't understand that, please enter %s <name> <price>.", addItem)
+					break
+				}
+				if err := receipt.InsertItem(nextReceiptItem+1, &models.ReceiptItem{Name: name, Price: price}); err != nil {
+					bot.enqueue("I can't add this item: %v.", err)
+					break
+				}
+				bot.enqueue("Added item %d.", current+2)
+				reportReconciled()
+				commit(fmt.Sprintf("Added item %d (%s)", current+2, name), before, current)
+			case strings.HasPrefix(message.Text, splitLine+" "):
+				priceArg, name, _ := strings.Cut(text[len(splitLine+" "):], " ")
+				price, ok := receipt.Currency.OrDefault().ParsePrice(priceArg)
+				if !ok {
+					bot.enqueue("I can't understand that, please enter %s <price> or %s <price> <name>.", splitLine, splitLine)
+					break
+				}
+				if err := receipt.SplitLine(nextReceiptItem, price, name); err != nil {
+					bot.enqueue("I can't split this line: %v.", err)
+					break
+				}
+				bot.enqueue("Moved %s to the new item %d.", receipt.Format(price), current+2)
+				commit(fmt.Sprintf("Moved %s of item %d to the new item %d", receipt.Format(price), current+1, current+2), before, current)
+			case message.Text == deleteItem || strings.HasPrefix(message.Text, deleteItem+" "):
+				item := nextReceiptItem
+				if arg := strings.TrimSpace(message.Text[len(deleteItem):]); arg != "" {
+					n, err := strconv.Atoi(arg)
+					if err != nil {
+						bot.enqueue("I can't understand that, please enter %s or %s <item_number>.", deleteItem, deleteItem)
+						break
+					}
+					item = n - 1
+				}
+				var name string
+				if item >= 0 && item < receipt.Len() {
+					name = receipt.Items[item].Name
 				}
-			case message.Text == undoLastDecision && lastModifiedReceiptItem >= 0:
-				nextReceiptItem = lastModifiedReceiptItem
-				lastModifiedReceiptItem = -1
-				receipt[nextReceiptItem].Owner = ""
-				storeCheckpoint()
+				if err := receipt.DeleteItem(item); err != nil {
+					bot.enqueue("I can't delete this item: %v.", err)
+					break
+				}
+				itemDeleted(item)
+				bot.enqueue("Deleted item %d.", item+1)
+				reportReconciled()
+				commit(fmt.Sprintf("Deleted item %d (%s)", item+1, name), before, current)
+			case message.Text == overrideTotal:
+				receipt.TotalOverridden = true
+				bot.enqueue("OK, I will ignore the difference to the printed total.")
+				commit("Accepted the difference to the printed total", before, current)
+			case message.Text == delayDecision:
+				nextReceiptItem = receipt.NextPendingItem(receipt.NextItem(nextReceiptItem))
+				commit(fmt.Sprintf("Delayed item %d (%s)", current+1, receipt.Items[current].Name), before, current)
 			default:
-				bot.sendOwnerChoice(lastModifiedReceiptItem)
-				continue
+				bot.enqueueOwnerChoice(receipt)
 			}
 
-			if receipt[nextReceiptItem].Owner == "" {
-				bot.sendReceiptItem(receipt[nextReceiptItem], lastModifiedReceiptItem)
+			if receipt.IsPending(nextReceiptItem) {
+				bot.sendReceiptItem(receipt, nextReceiptItem)
 			} else {
 				bot.sendPayerChoice(receipt)
 				botState = botStateWaitingForPayer
 			}
 		case botStateWaitingForPayer:
 			payer = models.ReceiptItemOwner(strings.TrimSpace(strings.ToLower(message.Text)))
-			if payer != models.Ana && payer != models.Matheus && payer != resetReceipt {
-				bot.send("Invalid choice. Choose one of {%s, %s, %s}.", models.Ana, models.Matheus, resetReceipt)
+			if payer == overrideTotal && !receipt.IsReconciled() {
+				before := snapshot()
+				receipt.TotalOverridden = true
+				commit("Accepted the difference to the printed total", before, nextReceiptItem)
+				bot.sendPayerChoice(receipt)
+			} else if !payer.In(bot.members()) && payer != resetReceipt {
+				bot.enqueue("Invalid choice. Choose one of {%s, %s}.", strings.Join(bot.memberCodes(), ", "), resetReceipt)
+				bot.sendPayerChoice(receipt)
 			} else if payer == resetReceipt {
 				softResetOption()
+			} else if !receipt.IsReconciled() {
+				bot.enqueue("I can't create the expenses until the difference to the printed total is resolved. Reset the receipt to fix the prices, or accept the difference.")
+				bot.sendPayerChoice(receipt)
+			} else if _, _, err := receipt.ComputeExpenses(bot.members(), payer, bot.location); err != nil {
+				bot.enqueue("I can't create the expenses: %v. Reset the receipt to fix the owners of the discounts.", err)
+				bot.sendPayerChoice(receipt)
 			} else {
-				bot.send("Please type in the name of the store.")
+				bot.sendStoreChoice(receipt)
 				botState = botStateWaitingForStore
 			}
 		case botStateWaitingForStore:
-			storeName := message.Text
-			if len(storeName) == 0 {
+			storeName := strings.TrimSpace(message.Text)
+			if strings.ToLower(storeName) == useReceiptStore && receipt.Store != "" {
+				storeName = receipt.Store
+			}
+			nonSharedExpense, sharedExpense, err := receipt.ComputeExpenses(bot.members(), payer, bot.location)
+			if err != nil {
+				bot.enqueue("I can't create the expenses: %v.", err)
+				bot.sendPayerChoice(receipt)
+				botState = botStateWaitingForPayer
+			} else if len(storeName) == 0 {
 				bot.send("Store name cannot be empty.")
 			} else {
-				nonSharedExpense, sharedExpense := receipt.ComputeExpenses(payer)
+				// the owners are recorded under the store they were suggested
+				// from, the printed one if any
+				if receipt.Store == "" {
+					receipt.Store = storeName
+				}
 				createNonSharedExpense(nonSharedExpense, storeName)
 				createSharedExpense(sharedExpense, storeName)
+				bot.enqueueUsageReport()
+				bot.recordOwners(ctx, receipt)
+				bot.cacheReceipt(ctx, receipt)
 				resetState()
 			}
 		default:
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// menuKind identifies the prompts whose menus are edited in place when
+	// a button is pressed, instead of sending a new message.
+	menuKind int
+)
+
+const (
+	menuOwner menuKind = iota + 1
+	menuPayer
+	menuStore
+	menuConfirm
+	menuSuggestions
+
+	buttonsPerRow = 3
+)
+
+// button returns a button whose data is the text of the equivalent typed
+// command, labelled with the command so it can still be typed.
+func button(label, command string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%s)", label, command), command)
+}
+
+// keyboard lays out the buttons of each group in rows of at most
+// buttonsPerRow buttons, starting a new row for each group.
+func keyboard(groups ...[]tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, group := range groups {
+		for len(group) > 0 {
+			n := min(len(group), buttonsPerRow)
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(group[:n]...))
+			group = group[n:]
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendMenu sends the queued messages with a keyboard. If the last update
+// was a button of a menu of the same kind, that message is edited in place.
+func (b *botClient) sendMenu(kind menuKind, markup tgbotapi.InlineKeyboardMarkup, format string, args ...interface{}) {
+	b.enqueue(format, args...)
+	fullText := strings.Join(b.msgQueue, "\n\n")
+	logrus.Infof("[%s] %s", b.account(), fullText)
+
+	if b.menuOpen() && b.menuMessageID == b.pressedMessageID && b.menuKind == kind {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(b.chatID, b.menuMessageID, fullText, markup)
+		if _, err := b.telegramClient.Send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+			logrus.Errorf("error editing message: %v\n\nmessage text:\n%s", err, fullText)
+		} else {
+			b.msgQueue = nil
+		}
+		return
+	}
+
+	if b.menuOpen() {
+		b.closeMenu()
+	}
+	msg := tgbotapi.NewMessage(b.chatID, fullText)
+	msg.ReplyMarkup = markup
+	sent, err := b.telegramClient.Send(msg)
+	if err != nil {
+		logrus.Errorf("error sending message: %v\n\nmessage text:\n%s", err, fullText)
+		return
+	}
+	b.msgQueue = nil
+	b.menuMessageID = sent.MessageID
+	b.menuKind = kind
+}
+
+// menuOpen tells whether the last menu sent still has its keyboard, i.e.
+// whether it needs to be closed before sending other messages.
+func (b *botClient) menuOpen() bool {
+	return b.menuMessageID != 0
+}
+
+// closeMenu removes the keyboard of the open menu, so old buttons can't be
+// pressed. Only call it if menuOpen, as it costs an API request.
+func (b *botClient) closeMenu() {
+	empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if _, err := b.telegramClient.Request(tgbotapi.NewEditMessageReplyMarkup(b.chatID, b.menuMessageID, empty)); err != nil {
+		logrus.Errorf("error removing keyboard: %v", err)
+	}
+	b.menuMessageID = 0
+}
+
+// updateMessage returns the message of an update. A button of the active
+// menu becomes a message whose text is the data of the button, so buttons
+// and typed commands are handled alike. It returns nil for other updates,
+// and for buttons pressed by someone else or while the bot should skip
+// messages, see shouldSkip.
+func (b *botClient) updateMessage(update tgbotapi.Update) *tgbotapi.Message {
+	if update.Message != nil {
+		b.pressedMessageID = 0
+		return update.Message
+	}
+	query := update.CallbackQuery
+	if query == nil || query.Message == nil {
+		return nil
+	}
+	message := &tgbotapi.Message{
+		MessageID: query.Message.MessageID,
+		From:      query.From,
+		Chat:      query.Message.Chat,
+		Text:      query.Data,
+	}
+	var answer string
+	switch {
+	case query.Message.MessageID != b.menuMessageID:
+		answer = "This menu is outdated."
+	case b.shouldSkip(message):
+		answer = "This menu is not for you."
+	}
+	if _, err := b.telegramClient.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+		logrus.Errorf("error answering callback query: %v", err)
+	}
+	if answer != "" {
+		return nil
+	}
+	b.pressedMessageID = query.Message.MessageID
+	return message
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/matheuscscp/splitwiser/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegram records the methods of the Telegram API requests.
+type fakeTelegram struct {
+	mu      sync.Mutex
+	methods []string
+	nextID  int
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	method := path.Base(r.URL.Path)
+	if method == "getMe" {
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"splitwiser_bot"}}`)
+		return
+	}
+	f.methods = append(f.methods, method)
+	f.nextID++
+	fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"chat":{"id":42}}}`, f.nextID)
+}
+
+func (f *fakeTelegram) takeMethods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	methods := f.methods
+	f.methods = nil
+	return methods
+}
+
+func TestMenus(t *testing.T) {
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	telegramClient, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", server.URL+"/bot%s/%s")
+	require.NoError(t, err)
+	b := &botClient{telegramClient: telegramClient, chatID: 42}
+	confirm := keyboard([]tgbotapi.InlineKeyboardButton{button("Continue", "y")})
+
+	b.send("hello")
+	b.send("again")
+	assert.Equal(t, []string{"sendMessage", "sendMessage"}, fake.takeMethods(), "no menu to close")
+
+	b.sendMenu(menuConfirm, confirm, "continue?")
+	assert.True(t, b.menuOpen())
+	b.send("ok")
+	b.send("done")
+	assert.Equal(t, []string{"sendMessage", "editMessageReplyMarkup", "sendMessage", "sendMessage"}, fake.takeMethods(),
+		"the menu is closed once")
+	assert.False(t, b.menuOpen())
+
+	b.sendMenu(menuConfirm, confirm, "continue?")
+	b.pressedMessageID = b.menuMessageID
+	b.sendMenu(menuConfirm, confirm, "continue again?")
+	assert.Equal(t, []string{"sendMessage", "editMessageText"}, fake.takeMethods(), "the pressed menu is edited in place")
+
+	b.pressedMessageID = 0
+	b.sendMenu(menuConfirm, confirm, "continue?")
+	assert.Equal(t, []string{"editMessageReplyMarkup", "sendMessage"}, fake.takeMethods(), "a typed reply closes the menu")
+}
+
+func TestUpdateMessage(t *testing.T) {
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	telegramClient, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", server.URL+"/bot%s/%s")
+	require.NoError(t, err)
+	b := &botClient{
+		telegramClient: telegramClient,
+		chatID:         42,
+		user:           "m",
+		conf: &config.Bot{Members: config.Members{
+			{Name: "Matheus", Code: "m", TelegramUserName: "matheus"},
+			{Name: "Ana", Code: "a", TelegramUserName: "ana"},
+		}},
+	}
+	b.sendMenu(menuConfirm, keyboard([]tgbotapi.InlineKeyboardButton{button("Continue", "y")}), "continue?")
+	fake.takeMethods()
+
+	for _, tt := range []struct {
+		name      string
+		messageID int
+		userName  string
+		chatID    int64
+		expected  bool
+	}{
+		{name: "outdated menu", messageID: b.menuMessageID - 1, userName: "matheus", chatID: 42},
+		{name: "other user", messageID: b.menuMessageID, userName: "ana", chatID: 42},
+		{name: "other chat", messageID: b.menuMessageID, userName: "matheus", chatID: 7},
+		{name: "active menu", messageID: b.menuMessageID, userName: "matheus", chatID: 42, expected: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			message := b.updateMessage(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "1",
+				From:    &tgbotapi.User{UserName: tt.userName},
+				Message: &tgbotapi.Message{MessageID: tt.messageID, Chat: &tgbotapi.Chat{ID: tt.chatID}},
+				Data:    "y",
+			}})
+			assert.Equal(t, []string{"answerCallbackQuery"}, fake.takeMethods())
+			if !tt.expected {
+				assert.Nil(t, message)
+				assert.Zero(t, b.pressedMessageID)
+				return
+			}
+			require.NotNil(t, message)
+			assert.Equal(t, "y", message.Text)
+			assert.Equal(t, b.menuMessageID, b.pressedMessageID)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package evalreceipts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matheuscscp/splitwiser/models"
+)
+
+type (
+	// Case is a receipt of the dataset: its images and the hand-labelled
+	// expected receipt.
+	Case struct {
+		Name     string
+		Images   [][]byte
+		Expected *models.Receipt
+	}
+)
+
+var (
+	imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+)
+
+// LoadDataset loads the cases of a directory. Each case is an expected
+// receipt "<name>.json", in the format of the JSON importer, and either an
+// image "<name>.jpg" (or .jpeg, .png, .webp, .gif) or a directory "<name>"
+// with the images of the pages, in the order of their file names.
+func LoadDataset(dir string) ([]*Case, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dataset directory '%s': %w", dir, err)
+	}
+	var cases []*Case
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading expected receipt '%s': %w", entry.Name(), err)
+		}
+		expected, err := models.JSONImporter{}.Import(b)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing expected receipt '%s': %w", entry.Name(), err)
+		}
+		images, err := loadImages(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, &Case{Name: name, Images: images, Expected: expected})
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+func loadImages(dir, name string) ([][]byte, error) {
+	var files []string
+	pagesDir := filepath.Join(dir, name)
+	if info, err := os.Stat(pagesDir); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pagesDir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading pages of '%s': %w", name, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+				files = append(files, filepath.Join(pagesDir, entry.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("the directory of pages of '%s' has no images", name)
+		}
+	} else {
+		for ext := range imageExtensions {
+			if _, err := os.Stat(filepath.Join(dir, name+ext)); err == nil {
+				files = append(files, filepath.Join(dir, name+ext))
+			}
+		}
+		if len(files) != 1 {
+			return nil, fmt.Errorf("expected receipt '%s' must have one image or a directory of pages, found %d images", name, len(files))
+		}
+	}
+	sort.Strings(files)
+	images := make([][]byte, len(files))
+	for i, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading image '%s': %w", file, err)
+		}
+		images[i] = b
+	}
+	return images, nil
+}
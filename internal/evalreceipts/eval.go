@@ -0,0 +1,125 @@
+package evalreceipts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+	"github.com/matheuscscp/splitwiser/models"
+)
+
+type (
+	// Result is the evaluation of a case.
+	Result struct {
+		Case  string
+		Score Score
+		// Currency is the currency of the expected receipt, which the
+		// price errors of Score are in.
+		Currency models.Currency
+		// Attempts is the number of requests, more than one if the model
+		// replied invalid receipts and was asked to fix them.
+		Attempts      int
+		Usage         openaipkg.Usage
+		PromptVersion string
+		// Err is why the case has no extracted receipt, if any.
+		Err error
+	}
+)
+
+const (
+	// maxAttempts is the number of requests per case, like in the bot.
+	maxAttempts = 3
+)
+
+// Evaluate extracts the receipt of each case and scores it against the
+// expected one. Invalid replies are corrected like in the bot. Errors of a
+// case are recorded in its result, only a canceled context stops it.
+func Evaluate(ctx context.Context, extractor openaipkg.ReceiptExtractor, cases []*Case) ([]*Result, error) {
+	results := make([]*Result, 0, len(cases))
+	for _, c := range cases {
+		result := &Result{Case: c.Name, Currency: c.Expected.Currency.OrDefault()}
+		receipt, err := extract(ctx, extractor, c.Images, result)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return results, ctxErr
+		}
+		result.Err = err
+		if receipt == nil {
+			receipt = &models.Receipt{}
+		}
+		result.Score = ScoreReceipt(c.Expected, receipt)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func extract(ctx context.Context, extractor openaipkg.ReceiptExtractor, images [][]byte, result *Result) (*models.Receipt, error) {
+	req := &openaipkg.ExtractionRequest{Images: images}
+	for result.Attempts < maxAttempts {
+		result.Attempts++
+		extraction, err := extractor.Extract(ctx, req)
+		if extraction != nil {
+			result.Usage.Model = extraction.Usage.Model
+			result.Usage.PromptTokens += extraction.Usage.PromptTokens
+			result.Usage.CompletionTokens += extraction.Usage.CompletionTokens
+			result.PromptVersion = extraction.PromptVersion
+		}
+		if errors.Is(err, openaipkg.ErrInvalidReply) {
+			req.Turns = append(req.Turns, openaipkg.ExtractionTurn{
+				Reply:    extraction.Content,
+				FollowUp: extraction.Correction(),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		receipt := extraction.Receipt
+		receipt.CompletePrices()
+		return receipt, nil
+	}
+	return nil, fmt.Errorf("model replied invalid receipts %d times in a row", maxAttempts)
+}
+
+// WriteReport writes a table with the score of each case followed by the
+// micro-averaged precision and recall of all the cases.
+func WriteReport(w io.Writer, results []*Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RECEIPT\tEXPECTED\tEXTRACTED\tPRECISION\tRECALL\tPRICE ERRORS\tTOTAL DIFF\tPRINTED TOTAL\tATTEMPTS\tERROR")
+	var total Score
+	var tokens, failed int
+	versions := map[string]bool{}
+	var versionList []string
+	for _, r := range results {
+		s := r.Score
+		total.Add(s)
+		tokens += r.Usage.PromptTokens + r.Usage.CompletionTokens
+		if r.PromptVersion != "" && !versions[r.PromptVersion] {
+			versions[r.PromptVersion] = true
+			versionList = append(versionList, r.PromptVersion)
+		}
+		printedTotal := "ok"
+		if s.PrintedTotalMismatch {
+			printedTotal = "mismatch"
+		}
+		errMsg := "-"
+		if r.Err != nil {
+			failed++
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.2f\t%d (%s)\t%s\t%s\t%d\t%s\n",
+			r.Case, s.Expected, s.Extracted, s.Precision(), s.Recall(),
+			s.PriceErrors, r.Currency.Format(s.PriceError), r.Currency.Format(s.TotalDifference), printedTotal, r.Attempts, errMsg)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	_, err := fmt.Fprintf(w, "\n%d receipts, %d failed: precision %.2f, recall %.2f, %d price errors, %d tokens, prompt %v\n",
+		len(results), failed, total.Precision(), total.Recall(), total.PriceErrors, tokens, versionList)
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
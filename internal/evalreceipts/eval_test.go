@@ -0,0 +1,128 @@
+package evalreceipts_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/internal/evalreceipts"
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const expectedTesco = `{
+	"currency": "GBP",
+	"total": 1799,
+	"items": [
+		{"name":"Smoky BBQ wings","price":399},
+		{"name":"Smoky BBQ wings Discount","price":-399},
+		{"name":"PopChips BBQ 5pk","price":249},
+		{"name":"RedHen Chicken Dippe","price":155},
+		{"name":"Whole Milk 2L","price":209},
+		{"name":"Coca Cola Regular","price":620},
+		{"name":"Ready Salted Crisps","price":119},
+		{"name":"Hummus Chips","price":149},
+		{"name":"Vegan Ice Sticks Alm","price":299}
+	]
+}`
+
+func writeDataset(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "single.json"), []byte(expectedTesco), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "single.jpg"), []byte("photo"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pages.json"), []byte(`{
+		"currency": "GBP",
+		"items": [{"name":"Whole Milk 2L","price":209}, {"name":"Bread","price":150}]
+	}`), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "pages"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pages", "2.png"), []byte("bottom"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pages", "1.png"), []byte("top"), 0o600))
+	return dir
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := writeDataset(t)
+	cases, err := evalreceipts.LoadDataset(dir)
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	assert.Equal(t, "pages", cases[0].Name)
+	assert.Equal(t, [][]byte{[]byte("top"), []byte("bottom")}, cases[0].Images)
+	assert.Equal(t, 2, cases[0].Expected.Len())
+	assert.Equal(t, "single", cases[1].Name)
+	assert.Equal(t, [][]byte{[]byte("photo")}, cases[1].Images)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orphan.json"), []byte(`{}`), 0o600))
+	_, err = evalreceipts.LoadDataset(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "orphan")
+}
+
+func TestEvaluate(t *testing.T) {
+	cases, err := evalreceipts.LoadDataset(writeDataset(t))
+	require.NoError(t, err)
+
+	extractor := &openaipkg.FakeReceiptExtractor{
+		Replies: []string{
+			`{"currency":"GBP","items":[]}`,
+			`{"currency":"GBP","items":[{"name":"Whole Milk 2L","price":209}]}`,
+			openaipkg.ExampleReply,
+		},
+		Usage: openaipkg.Usage{Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 10},
+	}
+	results, err := evalreceipts.Evaluate(context.Background(), extractor, cases)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	pages := results[0]
+	assert.NoError(t, pages.Err)
+	assert.Equal(t, 2, pages.Attempts)
+	assert.Equal(t, 220, pages.Usage.PromptTokens+pages.Usage.CompletionTokens)
+	assert.Equal(t, 1.0, pages.Score.Precision())
+	assert.Equal(t, 0.5, pages.Score.Recall())
+
+	single := results[1]
+	assert.NoError(t, single.Err)
+	assert.Equal(t, 1, single.Attempts)
+	assert.Equal(t, 1.0, single.Score.Precision())
+	assert.Equal(t, 1.0, single.Score.Recall())
+	assert.False(t, single.Score.PrintedTotalMismatch)
+
+	var report bytes.Buffer
+	require.NoError(t, evalreceipts.WriteReport(&report, results))
+	assert.Contains(t, report.String(), "2 receipts, 0 failed: precision 1.00, recall 0.91")
+}
+
+func TestRunRecordAndReplay(t *testing.T) {
+	dir := writeDataset(t)
+	recordings := filepath.Join(t.TempDir(), "recordings")
+
+	var recorded bytes.Buffer
+	require.NoError(t, evalreceipts.Run(context.Background(), evalreceipts.Options{
+		Dir:       dir,
+		Extractor: openaipkg.ExtractorOptions{Provider: openaipkg.ProviderFake},
+		Record:    recordings,
+	}, &recorded))
+	files, err := os.ReadDir(recordings)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	var replayed bytes.Buffer
+	require.NoError(t, evalreceipts.Run(context.Background(), evalreceipts.Options{
+		Dir:    dir,
+		Replay: recordings,
+	}, &replayed))
+	assert.Equal(t, recorded.String(), replayed.String())
+
+	var missing bytes.Buffer
+	require.NoError(t, evalreceipts.Run(context.Background(), evalreceipts.Options{
+		Dir:    dir,
+		Replay: t.TempDir(),
+	}, &missing))
+	assert.Contains(t, missing.String(), "2 receipts, 2 failed")
+	assert.Contains(t, missing.String(), openaipkg.ErrNoRecording.Error())
+}
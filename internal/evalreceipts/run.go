@@ -0,0 +1,65 @@
+package evalreceipts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+)
+
+type (
+	// Options configures an evaluation run.
+	Options struct {
+		// Dir is the dataset, see LoadDataset.
+		Dir       string
+		Extractor openaipkg.ExtractorOptions
+		// PromptPath is the prompt template, the embedded one if empty.
+		PromptPath string
+		Prompt     openaipkg.PromptData
+		// Replay replays the replies recorded in this directory instead of
+		// calling the extractor, so no network is needed.
+		Replay string
+		// Record records the replies of the extractor in this directory.
+		Record string
+	}
+)
+
+// Run evaluates the extractor over the dataset and writes the report.
+func Run(ctx context.Context, opts Options, w io.Writer) error {
+	cases, err := LoadDataset(opts.Dir)
+	if err != nil {
+		return err
+	}
+	if len(cases) == 0 {
+		return fmt.Errorf("dataset '%s' has no receipts", opts.Dir)
+	}
+
+	var extractor openaipkg.ReceiptExtractor
+	if opts.Replay != "" {
+		extractor = &openaipkg.ReplayExtractor{Dir: opts.Replay}
+	} else {
+		prompt, err := openaipkg.LoadPrompt(opts.PromptPath, opts.Prompt)
+		if err != nil {
+			return fmt.Errorf("error loading extraction prompt: %w", err)
+		}
+		opts.Extractor.Prompt = prompt
+		extractor, err = openaipkg.NewReceiptExtractor(opts.Extractor)
+		if err != nil {
+			return fmt.Errorf("error creating receipt extractor: %w", err)
+		}
+	}
+	if opts.Record != "" {
+		if err := os.MkdirAll(opts.Record, 0o755); err != nil {
+			return fmt.Errorf("error creating recordings directory '%s': %w", opts.Record, err)
+		}
+		extractor = &openaipkg.RecordingExtractor{Extractor: extractor, Dir: opts.Record}
+	}
+
+	results, err := Evaluate(ctx, extractor, cases)
+	if err != nil {
+		return fmt.Errorf("error evaluating extractor: %w", err)
+	}
+	return WriteReport(w, results)
+}
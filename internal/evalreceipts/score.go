@@ -0,0 +1,163 @@
+package evalreceipts
+
+import (
+	"strings"
+
+	"github.com/matheuscscp/splitwiser/models"
+)
+
+type (
+	// Score compares an extracted receipt with the expected one.
+	Score struct {
+		Expected  int
+		Extracted int
+		// Matched is the number of extracted items matched to an expected
+		// item by name, see ScoreReceipt.
+		Matched int
+		// PriceErrors is the number of matched items with a wrong price, and
+		// PriceError the sum of the absolute errors.
+		PriceErrors int
+		PriceError  models.PriceInCents
+		// TotalDifference is the sum of the extracted items minus the sum
+		// of the expected items.
+		TotalDifference models.PriceInCents
+		// PrintedTotalMismatch tells that the extracted printed total is not
+		// the expected one, if the expected receipt has one.
+		PrintedTotalMismatch bool
+	}
+)
+
+const (
+	// minNameSimilarity is the least similarity of the names of items
+	// matched with different names.
+	minNameSimilarity = 0.8
+)
+
+// ScoreReceipt matches the extracted items to the expected ones by name,
+// first the ones with the same normalized name and then the ones with
+// similar names, preferring the ones with the same price.
+func ScoreReceipt(expected, extracted *models.Receipt) Score {
+	s := Score{
+		Expected:        expected.Len(),
+		Extracted:       extracted.Len(),
+		TotalDifference: itemsTotal(extracted) - itemsTotal(expected),
+	}
+	if expected.Total != nil {
+		s.PrintedTotalMismatch = extracted.Len() == 0 || extracted.Total == nil || *extracted.Total != *expected.Total
+	}
+	if extracted.Len() == 0 {
+		return s
+	}
+
+	matchedExtracted := make([]bool, extracted.Len())
+	matchedExpected := make([]bool, expected.Len())
+	match := func(accept func(a, b string) bool) {
+		for i, want := range expected.Items {
+			if matchedExpected[i] {
+				continue
+			}
+			best := -1
+			for j, got := range extracted.Items {
+				if matchedExtracted[j] || !accept(normalizeName(want.Name), normalizeName(got.Name)) {
+					continue
+				}
+				if best < 0 || (got.Price == want.Price && extracted.Items[best].Price != want.Price) {
+					best = j
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			matchedExpected[i], matchedExtracted[best] = true, true
+			s.Matched++
+			if diff := extracted.Items[best].Price - want.Price; diff != 0 {
+				s.PriceErrors++
+				if diff < 0 {
+					diff = -diff
+				}
+				s.PriceError += diff
+			}
+		}
+	}
+	match(func(a, b string) bool { return a == b })
+	match(func(a, b string) bool { return similarity(a, b) >= minNameSimilarity })
+	return s
+}
+
+// Precision is the fraction of the extracted items that were expected.
+func (s Score) Precision() float64 {
+	if s.Extracted == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Extracted)
+}
+
+// Recall is the fraction of the expected items that were extracted.
+func (s Score) Recall() float64 {
+	if s.Expected == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Expected)
+}
+
+// Add sums the scores of receipts. The total differences are summed in
+// absolute value, so they don't cancel out.
+func (s *Score) Add(other Score) {
+	s.Expected += other.Expected
+	s.Extracted += other.Extracted
+	s.Matched += other.Matched
+	s.PriceErrors += other.PriceErrors
+	s.PriceError += other.PriceError
+	if other.TotalDifference < 0 {
+		s.TotalDifference -= other.TotalDifference
+	} else {
+		s.TotalDifference += other.TotalDifference
+	}
+	s.PrintedTotalMismatch = s.PrintedTotalMismatch || other.PrintedTotalMismatch
+}
+
+func itemsTotal(r *models.Receipt) models.PriceInCents {
+	var total models.PriceInCents
+	for i := 0; i < r.Len(); i++ {
+		total += r.Items[i].Price
+	}
+	return total
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// similarity is one minus the edit distance of the names over the length of
+// the longest one.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
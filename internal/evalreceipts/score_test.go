@@ -0,0 +1,96 @@
+package evalreceipts_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/internal/evalreceipts"
+	"github.com/matheuscscp/splitwiser/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreReceipt(t *testing.T) {
+	total := func(p models.PriceInCents) *models.PriceInCents { return &p }
+	expected := &models.Receipt{
+		Items: []*models.ReceiptItem{
+			{Name: "Whole Milk 2L", Price: 209},
+			{Name: "Coca Cola Regular", Price: 620},
+			{Name: "Hummus Chips", Price: 149},
+			{Name: "Hummus Chips", Price: 139},
+		},
+		Total: total(1117),
+	}
+
+	for _, tt := range []struct {
+		name      string
+		extracted *models.Receipt
+		score     evalreceipts.Score
+		precision float64
+		recall    float64
+	}{
+		{
+			name:      "perfect",
+			extracted: expected,
+			score:     evalreceipts.Score{Expected: 4, Extracted: 4, Matched: 4},
+			precision: 1,
+			recall:    1,
+		},
+		{
+			name: "similar names, wrong price and an extra item",
+			extracted: &models.Receipt{
+				Items: []*models.ReceiptItem{
+					{Name: "whole  milk 2l", Price: 209},
+					{Name: "Coca Cola Regulr", Price: 610},
+					{Name: "Hummus Chips", Price: 139},
+					{Name: "Hummus Chips", Price: 149},
+					{Name: "Bag", Price: 10},
+				},
+				Total: total(1117),
+			},
+			score: evalreceipts.Score{
+				Expected:        4,
+				Extracted:       5,
+				Matched:         4,
+				PriceErrors:     1,
+				PriceError:      10,
+				TotalDifference: 0,
+			},
+			precision: 0.8,
+			recall:    1,
+		},
+		{
+			name: "missing items and printed total",
+			extracted: &models.Receipt{
+				Items: []*models.ReceiptItem{
+					{Name: "Whole Milk 2L", Price: 209},
+					{Name: "Sparkling Water", Price: 100},
+				},
+			},
+			score: evalreceipts.Score{
+				Expected:             4,
+				Extracted:            2,
+				Matched:              1,
+				TotalDifference:      -808,
+				PrintedTotalMismatch: true,
+			},
+			precision: 0.5,
+			recall:    0.25,
+		},
+		{
+			name:      "nothing extracted",
+			extracted: &models.Receipt{},
+			score: evalreceipts.Score{
+				Expected:             4,
+				TotalDifference:      -1117,
+				PrintedTotalMismatch: true,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			score := evalreceipts.ScoreReceipt(expected, tt.extracted)
+			assert.Equal(t, tt.score, score)
+			assert.InDelta(t, tt.precision, score.Precision(), 1e-9)
+			assert.InDelta(t, tt.recall, score.Recall(), 1e-9)
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package openaipkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/matheuscscp/splitwiser/models"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type (
+	// ReceiptExtractor extracts receipts from images with a model.
+	ReceiptExtractor interface {
+		// Extract asks the model for the receipt in the images of the request.
+		// If the reply is not a valid receipt, the returned error wraps
+		// ErrInvalidReply and the extraction still has the raw reply and
+		// the problems found.
+		Extract(ctx context.Context, req *ExtractionRequest) (*Extraction, error)
+	}
+
+	// ExtractionRequest is a conversation with a model about a receipt.
+	ExtractionRequest struct {
+		// Images are photos or pages of a single receipt, in order.
+		Images [][]byte
+		// Turns are the previous replies of the model, each followed by a
+		// prompt asking for changes, like the ones of the user or the
+		// corrections of invalid replies.
+		Turns []ExtractionTurn
+	}
+
+	// ExtractionTurn ...
+	ExtractionTurn struct {
+		Reply    string
+		FollowUp string
+	}
+
+	// Extraction is the reply of a model to an extraction request.
+	Extraction struct {
+		// Content is the raw reply, the arguments of the function call.
+		Content string
+		// Receipt is parsed from the reply, nil if the reply is invalid.
+		Receipt *models.Receipt
+		// Problems are why the reply is invalid.
+		Problems []string
+		// Usage is the tokens used by the request.
+		Usage Usage
+		// PromptVersion is the version of the prompt, see Prompt.
+		PromptVersion string
+	}
+
+	// Usage is the tokens used by a request to a model.
+	Usage struct {
+		Model            string
+		PromptTokens     int
+		CompletionTokens int
+	}
+
+	// ExtractorOptions selects and configures a ReceiptExtractor.
+	ExtractorOptions struct {
+		Provider  string
+		Token     string
+		BaseURL   string
+		Model     string
+		MaxTokens int
+		// Prompt is the rendered default prompt template if nil.
+		Prompt *Prompt
+	}
+
+	// extractedReceipt has only the fields of models.ReceiptSchema, so the
+	// replies of models can't set the fields used while splitting, like the
+	// owners or the discounted items.
+	extractedReceipt struct {
+		Items         []*extractedItem     `json:"items"`
+		Currency      models.Currency      `json:"currency"`
+		Store         string               `json:"store"`
+		Date          string               `json:"date"`
+		Total         *models.PriceInCents `json:"total"`
+		PaymentMethod string               `json:"payment_method"`
+	}
+
+	extractedItem struct {
+		Name      string              `json:"name"`
+		Price     models.PriceInCents `json:"price"`
+		Quantity  int                 `json:"quantity"`
+		UnitPrice models.PriceInCents `json:"unit_price"`
+	}
+
+	// FakeReceiptExtractor is a deterministic ReceiptExtractor for tests.
+	// It replies Replies in order, repeating the last one, or ExampleReply
+	// if there are none, with the same Usage, and records the requests.
+	FakeReceiptExtractor struct {
+		Replies       []string
+		Usage         Usage
+		PromptVersion string
+		Requests      []*ExtractionRequest
+	}
+
+	chatExtractor struct {
+		client    *openai.Client
+		model     string
+		maxTokens int
+		prompt    *Prompt
+	}
+)
+
+const (
+	// ProviderOpenAI uses the OpenAI API.
+	ProviderOpenAI = "openai"
+	// ProviderOpenAICompatible uses an OpenAI-compatible API at a base URL,
+	// like the ones of self-hosted models.
+	ProviderOpenAICompatible = "openai-compatible"
+	// ProviderFake replies the example receipt of the default prompt.
+	ProviderFake = "fake"
+
+	// DefaultModel ...
+	DefaultModel = openai.GPT4o
+	// DefaultMaxTokens ...
+	DefaultMaxTokens = 4096
+
+	// submitReceiptFunction is the function the model must call with the
+	// receipt, whose parameters are models.ReceiptSchema.
+	submitReceiptFunction = "submit_receipt"
+
+	// ExampleReply is the example receipt of the default prompt.
+	ExampleReply = `{
+	"store": "Tesco Express",
+	"date": "2024-03-09 18:42",
+	"total": 1799,
+	"payment_method": "card",
+	"currency": "GBP",
+	"items": [
+		{"name":"Smoky BBQ wings","price":399},
+		{"name":"Smoky BBQ wings Discount","price":-399},
+		{"name":"PopChips BBQ 5pk","price":249},
+		{"name":"RedHen Chicken Dippe","price":155},
+		{"name":"Whole Milk 2L","price":209},
+		{"name":"Coca Cola Regular","quantity":2,"unit_price":310,"price":620},
+		{"name":"Ready Salted Crisps","price":119},
+		{"name":"Hummus Chips","price":149},
+		{"name":"Vegan Ice Sticks Alm","price":299}
+	]
+}`
+)
+
+var (
+	// ErrInvalidReply ...
+	ErrInvalidReply = errors.New("model replied an invalid receipt")
+)
+
+// NewReceiptExtractor ...
+func NewReceiptExtractor(opts ExtractorOptions) (ReceiptExtractor, error) {
+	if opts.Model == "" {
+		opts.Model = DefaultModel
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.Prompt == nil {
+		prompt, err := LoadPrompt("", PromptData{})
+		if err != nil {
+			return nil, err
+		}
+		opts.Prompt = prompt
+	}
+	switch opts.Provider {
+	case "", ProviderOpenAI:
+		return &chatExtractor{
+			client:    openai.NewClient(opts.Token),
+			model:     opts.Model,
+			maxTokens: opts.MaxTokens,
+			prompt:    opts.Prompt,
+		}, nil
+	case ProviderOpenAICompatible:
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("provider '%s' needs a base URL", opts.Provider)
+		}
+		clientConf := openai.DefaultConfig(opts.Token)
+		clientConf.BaseURL = opts.BaseURL
+		return &chatExtractor{
+			client:    openai.NewClientWithConfig(clientConf),
+			model:     opts.Model,
+			maxTokens: opts.MaxTokens,
+			prompt:    opts.Prompt,
+		}, nil
+	case ProviderFake:
+		return &FakeReceiptExtractor{PromptVersion: opts.Prompt.Version}, nil
+	default:
+		return nil, fmt.Errorf("unknown receipt extraction provider '%s'", opts.Provider)
+	}
+}
+
+func (c *chatExtractor) Extract(ctx context.Context, req *ExtractionRequest) (*Extraction, error) {
+	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		MaxTokens: c.maxTokens,
+		Model:     c.model,
+		Messages:  c.chatMessages(req),
+		Tools: []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        submitReceiptFunction,
+				Description: "Submits the receipt parsed from the images.",
+				Parameters:  models.ReceiptSchema(),
+				Strict:      true,
+			},
+		}},
+		ToolChoice: openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: submitReceiptFunction},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("chat completion has no choices")
+	}
+	message := resp.Choices[0].Message
+	content := message.Content // some OpenAI-compatible servers ignore the tool choice
+	for _, call := range message.ToolCalls {
+		if call.Function.Name == submitReceiptFunction {
+			content = call.Function.Arguments
+			break
+		}
+	}
+	extraction, err := parseExtraction(content)
+	extraction.Usage = Usage{
+		Model:            c.model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	extraction.PromptVersion = c.prompt.Version
+	return extraction, err
+}
+
+// chatMessages returns the conversation of the request as chat messages.
+func (c *chatExtractor) chatMessages(req *ExtractionRequest) []openai.ChatCompletionMessage {
+	parts := []openai.ChatMessagePart{
+		{
+			Type: openai.ChatMessagePartTypeText,
+			Text: c.prompt.Text,
+		},
+	}
+	for _, image := range req.Images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL: fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(image), base64.StdEncoding.EncodeToString(image)),
+			},
+		})
+	}
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:         openai.ChatMessageRoleUser,
+			MultiContent: parts,
+		},
+	}
+	// each reply is a call of the function, answered with the follow-up
+	for i, turn := range req.Turns {
+		callID := fmt.Sprintf("call_%d", i)
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{{
+				ID:   callID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      submitReceiptFunction,
+					Arguments: turn.Reply,
+				},
+			}},
+		}, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			ToolCallID: callID,
+			Content:    turn.FollowUp,
+		})
+	}
+	return messages
+}
+
+// parseExtraction decodes and validates the receipt in the reply of a model.
+func parseExtraction(content string) (*Extraction, error) {
+	extraction := &Extraction{Content: content}
+	var extracted extractedReceipt
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&extracted); err != nil {
+		extraction.Problems = []string{fmt.Sprintf("the arguments are not a valid receipt: %v", err)}
+		return extraction, fmt.Errorf("%w: %v", ErrInvalidReply, err)
+	}
+	receipt := extracted.receipt()
+	if err := receipt.Validate(); err != nil {
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			extraction.Problems = validationErr.Problems
+		}
+		return extraction, fmt.Errorf("%w: %v", ErrInvalidReply, err)
+	}
+	extraction.Receipt = receipt
+	return extraction, nil
+}
+
+func (e *extractedReceipt) receipt() *models.Receipt {
+	receipt := &models.Receipt{
+		Currency:      e.Currency,
+		Store:         e.Store,
+		Date:          e.Date,
+		Total:         e.Total,
+		PaymentMethod: e.PaymentMethod,
+	}
+	for _, item := range e.Items {
+		if item == nil {
+			receipt.Items = append(receipt.Items, nil)
+			continue
+		}
+		receipt.Items = append(receipt.Items, &models.ReceiptItem{
+			Name:      item.Name,
+			Price:     item.Price,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		})
+	}
+	return receipt
+}
+
+// Correction returns the follow-up asking the model to fix the problems of
+// an invalid reply.
+func (e *Extraction) Correction() string {
+	return fmt.Sprintf(`The receipt has the following problems:
+
+- %s
+
+Please check the images again, fix these problems and call the function with the whole receipt again.`, strings.Join(e.Problems, "\n- "))
+}
+
+// Extract ...
+func (f *FakeReceiptExtractor) Extract(ctx context.Context, req *ExtractionRequest) (*Extraction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reply := ExampleReply
+	if n := len(f.Replies); n > 0 {
+		reply = f.Replies[min(len(f.Requests), n-1)]
+	}
+	f.Requests = append(f.Requests, req)
+	extraction, err := parseExtraction(reply)
+	extraction.Usage = f.Usage
+	extraction.PromptVersion = f.PromptVersion
+	return extraction, err
+}
@@ -0,0 +1,192 @@
+package openaipkg_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/cache"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReceiptExtractor(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts openaipkg.ExtractorOptions
+		err  string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "openai",
+			opts: openaipkg.ExtractorOptions{Provider: openaipkg.ProviderOpenAI, Model: "gpt-4o-mini"},
+		},
+		{
+			name: "fake",
+			opts: openaipkg.ExtractorOptions{Provider: openaipkg.ProviderFake},
+		},
+		{
+			name: "compatible without base URL",
+			opts: openaipkg.ExtractorOptions{Provider: openaipkg.ProviderOpenAICompatible},
+			err:  "provider 'openai-compatible' needs a base URL",
+		},
+		{
+			name: "unknown",
+			opts: openaipkg.ExtractorOptions{Provider: "llama"},
+			err:  "unknown receipt extraction provider 'llama'",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor, err := openaipkg.NewReceiptExtractor(tt.opts)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, extractor)
+		})
+	}
+}
+
+func TestOpenAICompatibleExtractor(t *testing.T) {
+	var requests []openai.ChatCompletionRequest
+	replies := []string{
+		`{"currency":"EUR","items":[{"name":"Milk","price":129}]}`,
+		`{"currency":"EUR","items":[{"name":"","price":129},{"name":"Bag","price":10}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{
+					Role: openai.ChatMessageRoleAssistant,
+					ToolCalls: []openai.ToolCall{{
+						ID:   "call_abc",
+						Type: openai.ToolTypeFunction,
+						Function: openai.FunctionCall{
+							Name:      "submit_receipt",
+							Arguments: replies[len(requests)],
+						},
+					}},
+				},
+			}},
+			Usage: openai.Usage{PromptTokens: 1000 + len(requests), CompletionTokens: 100},
+		}
+		requests = append(requests, req)
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	defer server.Close()
+
+	extractor, err := openaipkg.NewReceiptExtractor(openaipkg.ExtractorOptions{
+		Provider:  openaipkg.ProviderOpenAICompatible,
+		Token:     "token",
+		BaseURL:   server.URL + "/v1",
+		Model:     "llava",
+		MaxTokens: 1000,
+	})
+	require.NoError(t, err)
+
+	req := &openaipkg.ExtractionRequest{Images: [][]byte{[]byte("\x89PNG\r\n\x1a\n"), []byte("\xff\xd8\xff")}}
+	extraction, err := extractor.Extract(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, &models.Receipt{
+		Currency: "EUR",
+		Items:    []*models.ReceiptItem{{Name: "Milk", Price: 129}},
+	}, extraction.Receipt)
+	assert.Equal(t, openaipkg.Usage{Model: "llava", PromptTokens: 1000, CompletionTokens: 100}, extraction.Usage)
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, "llava", requests[0].Model)
+	assert.Equal(t, 1000, requests[0].MaxTokens)
+	require.Len(t, requests[0].Tools, 1)
+	assert.Equal(t, "submit_receipt", requests[0].Tools[0].Function.Name)
+	assert.True(t, requests[0].Tools[0].Function.Strict)
+	assert.Equal(t, map[string]interface{}{
+		"type":     "function",
+		"function": map[string]interface{}{"name": "submit_receipt"},
+	}, requests[0].ToolChoice)
+	require.Len(t, requests[0].Messages, 1)
+	parts := requests[0].Messages[0].MultiContent
+	require.Len(t, parts, 3)
+	assert.Equal(t, openai.ChatMessagePartTypeText, parts[0].Type)
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", parts[1].ImageURL.URL)
+	assert.Equal(t, "data:image/jpeg;base64,/9j/", parts[2].ImageURL.URL)
+
+	req.Turns = append(req.Turns, openaipkg.ExtractionTurn{Reply: extraction.Content, FollowUp: "add the bag"})
+	extraction, err = extractor.Extract(context.Background(), req)
+	assert.ErrorIs(t, err, openaipkg.ErrInvalidReply)
+	require.NotNil(t, extraction)
+	assert.Equal(t, replies[1], extraction.Content)
+	assert.Nil(t, extraction.Receipt)
+	assert.Equal(t, []string{"item 1 has no name"}, extraction.Problems)
+	assert.Equal(t, 1001, extraction.Usage.PromptTokens)
+	assert.Contains(t, extraction.Correction(), "- item 1 has no name\n")
+
+	require.Len(t, requests, 2)
+	messages := requests[1].Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, openai.ChatMessageRoleAssistant, messages[1].Role)
+	require.Len(t, messages[1].ToolCalls, 1)
+	assert.Equal(t, replies[0], messages[1].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, openai.ChatMessageRoleTool, messages[2].Role)
+	assert.Equal(t, messages[1].ToolCalls[0].ID, messages[2].ToolCallID)
+	assert.Equal(t, "add the bag", messages[2].Content)
+}
+
+func TestFakeReceiptExtractor(t *testing.T) {
+	ctx := context.Background()
+
+	example := &openaipkg.FakeReceiptExtractor{}
+	extraction, err := example.Extract(ctx, &openaipkg.ExtractionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, openaipkg.ExampleReply, extraction.Content)
+	assert.Equal(t, "Tesco Express", extraction.Receipt.Store)
+	assert.Equal(t, 9, extraction.Receipt.Len())
+
+	fake := &openaipkg.FakeReceiptExtractor{Replies: []string{"```json\n{}\n```", `{"items":[{"name":"Bread","price":250}]}`}}
+	req := &openaipkg.ExtractionRequest{Images: [][]byte{[]byte("image")}}
+	extraction, err = fake.Extract(ctx, req)
+	assert.ErrorIs(t, err, openaipkg.ErrInvalidReply)
+	assert.Len(t, extraction.Problems, 1)
+	for i := 0; i < 2; i++ {
+		extraction, err = fake.Extract(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 1, extraction.Receipt.Len())
+	}
+	assert.Equal(t, []*openaipkg.ExtractionRequest{req, req, req}, fake.Requests)
+}
+
+func TestFakeReceiptExtractorInternalFields(t *testing.T) {
+	for _, reply := range []string{
+		`{"bogus":1,"items":[{"name":"Bread","price":250}]}`,
+		`{"items":[{"name":"Bread","price":250,"owner":"zz"}]}`,
+		`{"items":[{"name":"Bread","price":250,"discount_of":42}]}`,
+		`{"items":[{"name":"Bread","price":250}],"total_overridden":true}`,
+	} {
+		fake := &openaipkg.FakeReceiptExtractor{Replies: []string{reply}}
+		extraction, err := fake.Extract(context.Background(), &openaipkg.ExtractionRequest{})
+		assert.ErrorIs(t, err, openaipkg.ErrInvalidReply, reply)
+		assert.Nil(t, extraction.Receipt, reply)
+	}
+}
+
+func TestExtractionRequestKey(t *testing.T) {
+	images := [][]byte{[]byte("page 1"), []byte("page 2")}
+	req := &openaipkg.ExtractionRequest{Images: images}
+	assert.Equal(t, cache.Key(images...), req.Key())
+
+	req.Turns = []openaipkg.ExtractionTurn{{Reply: "{}", FollowUp: "the milk is 1.99"}}
+	assert.Equal(t, cache.Key(append(images, []byte("{}"), []byte("the milk is 1.99"))...), req.Key())
+	assert.NotEqual(t, cache.Key(images...), req.Key())
+}
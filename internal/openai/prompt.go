@@ -0,0 +1,86 @@
+package openaipkg
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/matheuscscp/splitwiser/models"
+)
+
+type (
+	// Prompt is an extraction prompt rendered from a template.
+	Prompt struct {
+		Text string
+		// Version is the name of the template followed by a hash of its
+		// contents, like "receipt@1a2b3c4d", so any change of the template
+		// changes the version.
+		Version string
+	}
+
+	// PromptData are the variables of the prompt templates.
+	PromptData struct {
+		// Members are the names of the members of the household.
+		Members []string
+		// Currency is the most likely currency of the receipts.
+		Currency models.Currency
+		// Language is the usual language of the receipts, like "German".
+		Language string
+		// Example is the example receipt, ExampleReply if empty.
+		Example string
+	}
+)
+
+const (
+	defaultPromptName = "receipt"
+)
+
+var (
+	//go:embed prompts/receipt.tmpl
+	defaultPromptTemplate string
+
+	promptFuncs = template.FuncMap{
+		// join joins names like "Ana, Bob and Carl"
+		"join": func(names []string) string {
+			if len(names) < 2 {
+				return strings.Join(names, "")
+			}
+			return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+		},
+	}
+)
+
+// LoadPrompt renders the prompt template at the path, or the embedded one
+// if the path is empty.
+func LoadPrompt(path string, data PromptData) (*Prompt, error) {
+	name, text := defaultPromptName, defaultPromptTemplate
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading prompt template '%s': %w", path, err)
+		}
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		text = string(b)
+	}
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt template: %w", err)
+	}
+	if data.Example == "" {
+		data.Example = ExampleReply
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("error rendering prompt template: %w", err)
+	}
+	sum := sha256.Sum256([]byte(text))
+	return &Prompt{
+		Text:    sb.String(),
+		Version: fmt.Sprintf("%s@%s", name, hex.EncodeToString(sum[:4])),
+	}, nil
+}
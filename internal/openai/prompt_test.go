@@ -0,0 +1,78 @@
+package openaipkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	openaipkg "github.com/matheuscscp/splitwiser/internal/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPrompt(t *testing.T) {
+	prompt, err := openaipkg.LoadPrompt("", openaipkg.PromptData{
+		Members:  []string{"Ana", "Bob", "Carl"},
+		Currency: "CHF",
+		Language: "German",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, prompt.Text, "helps Ana, Bob and Carl split")
+	assert.Contains(t, prompt.Text, `it's most likely "CHF"`)
+	assert.Contains(t, prompt.Text, "The receipts are usually in German.")
+	assert.Contains(t, prompt.Text, openaipkg.ExampleReply)
+	assert.NotContains(t, prompt.Text, "Matheus")
+	assert.Regexp(t, regexp.MustCompile(`^receipt@[0-9a-f]{8}$`), prompt.Version)
+
+	bare, err := openaipkg.LoadPrompt("", openaipkg.PromptData{})
+	require.NoError(t, err)
+	assert.Contains(t, bare.Text, "helps a household split")
+	assert.NotContains(t, bare.Text, "most likely")
+	assert.NotContains(t, bare.Text, "usually in")
+	assert.Equal(t, prompt.Version, bare.Version)
+}
+
+func TestLoadPromptOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "short.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("Receipt for {{join .Members}} in {{.Currency}}."), 0o600))
+	prompt, err := openaipkg.LoadPrompt(path, openaipkg.PromptData{Members: []string{"Ana"}, Currency: "EUR"})
+	require.NoError(t, err)
+	assert.Equal(t, "Receipt for Ana in EUR.", prompt.Text)
+	assert.Regexp(t, regexp.MustCompile(`^short@[0-9a-f]{8}$`), prompt.Version)
+
+	require.NoError(t, os.WriteFile(path, []byte("Receipt for {{join .Members}}."), 0o600))
+	changed, err := openaipkg.LoadPrompt(path, openaipkg.PromptData{Members: []string{"Ana"}})
+	require.NoError(t, err)
+	assert.NotEqual(t, prompt.Version, changed.Version)
+
+	for _, tt := range []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{
+			name:     "invalid template",
+			template: "{{.Members",
+			err:      "error parsing prompt template",
+		},
+		{
+			name:     "unknown variable",
+			template: "{{.Household}}",
+			err:      "error rendering prompt template",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".tmpl")
+			require.NoError(t, os.WriteFile(path, []byte(tt.template), 0o600))
+			_, err := openaipkg.LoadPrompt(path, openaipkg.PromptData{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	_, err = openaipkg.LoadPrompt(filepath.Join(dir, "missing.tmpl"), openaipkg.PromptData{})
+	assert.Error(t, err)
+}
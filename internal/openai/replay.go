@@ -0,0 +1,100 @@
+package openaipkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/matheuscscp/splitwiser/services/cache"
+)
+
+type (
+	// RecordingExtractor is a ReceiptExtractor that records the replies of
+	// another one in a directory, to be replayed by a ReplayExtractor.
+	RecordingExtractor struct {
+		Extractor ReceiptExtractor
+		Dir       string
+	}
+
+	// ReplayExtractor is a ReceiptExtractor that replays the replies
+	// recorded by a RecordingExtractor, so it needs no network.
+	ReplayExtractor struct {
+		Dir string
+	}
+
+	// recording is a reply recorded for a request.
+	recording struct {
+		Content          string `json:"content"`
+		Model            string `json:"model,omitempty"`
+		PromptTokens     int    `json:"prompt_tokens,omitempty"`
+		CompletionTokens int    `json:"completion_tokens,omitempty"`
+		PromptVersion    string `json:"prompt_version,omitempty"`
+	}
+)
+
+var (
+	// ErrNoRecording ...
+	ErrNoRecording = errors.New("no recorded reply for the request")
+)
+
+// Key returns the cache.Key of the images and the turns of the request,
+// which names its recording.
+func (req *ExtractionRequest) Key() string {
+	contents := append([][]byte{}, req.Images...)
+	for _, turn := range req.Turns {
+		contents = append(contents, []byte(turn.Reply), []byte(turn.FollowUp))
+	}
+	return cache.Key(contents...)
+}
+
+// Extract ...
+func (r *RecordingExtractor) Extract(ctx context.Context, req *ExtractionRequest) (*Extraction, error) {
+	extraction, err := r.Extractor.Extract(ctx, req)
+	if extraction == nil {
+		return nil, err
+	}
+	b, mErr := json.MarshalIndent(recording{
+		Content:          extraction.Content,
+		Model:            extraction.Usage.Model,
+		PromptTokens:     extraction.Usage.PromptTokens,
+		CompletionTokens: extraction.Usage.CompletionTokens,
+		PromptVersion:    extraction.PromptVersion,
+	}, "", "  ")
+	if mErr != nil {
+		return nil, fmt.Errorf("error marshaling recording: %w", mErr)
+	}
+	if wErr := os.WriteFile(filepath.Join(r.Dir, req.Key()+".json"), b, 0o644); wErr != nil {
+		return nil, fmt.Errorf("error writing recording: %w", wErr)
+	}
+	return extraction, err
+}
+
+// Extract ...
+func (r *ReplayExtractor) Extract(ctx context.Context, req *ExtractionRequest) (*Extraction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key := req.Key()
+	b, err := os.ReadFile(filepath.Join(r.Dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoRecording, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading recording: %w", err)
+	}
+	var rec recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("error unmarshaling recording: %w", err)
+	}
+	extraction, err := parseExtraction(rec.Content)
+	extraction.Usage = Usage{
+		Model:            rec.Model,
+		PromptTokens:     rec.PromptTokens,
+		CompletionTokens: rec.CompletionTokens,
+	}
+	extraction.PromptVersion = rec.PromptVersion
+	return extraction, err
+}
@@ -222,7 +222,7 @@ func (c *controller) sendSinglePageApp() {
 		<div id="form" hidden>
 			<div id="error-message" hidden></div>
 
-			<label for="user">User ('a' or 'm'):</label><br>
+			<label for="user">User (your member code):</label><br>
 			<input type="text" id="user" name="user"><br>
 
 			<label for="password">Password:</label><br>
@@ -279,7 +279,7 @@ func (c *controller) checkAuthentication() (models.ReceiptItemOwner, error) {
 		return "", fmt.Errorf("error getting subject from token: %w", err)
 	}
 	user := models.ReceiptItemOwner(sub)
-	if user != models.Ana && user != models.Matheus {
+	if _, ok := c.conf.Members.Get(user); !ok {
 		return "", errInvalidUser
 	}
 	return user, nil
@@ -294,7 +294,7 @@ func (c *controller) checkUserAndPassword() (models.ReceiptItemOwner, error) {
 		return "", fmt.Errorf("error unmarshaling payload: %w", err)
 	}
 	user := models.ReceiptItemOwner(strings.TrimSpace(strings.ToLower(payload.User)))
-	if user != models.Ana && user != models.Matheus {
+	if _, ok := c.conf.Members.Get(user); !ok {
 		return "", errInvalidUser
 	}
 	if payload.Password != c.conf.Password {
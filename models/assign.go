@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseItemRanges parses item numbers and ranges, like "3-10,12 15", into
+// the indexes of the items of a receipt with n items, in order and without
+// repetitions.
+func ParseItemRanges(s string, n int) ([]int, error) {
+	seen := make([]bool, n)
+	var items []int
+	for _, tok := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to, isRange := strings.Cut(tok, "-")
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item number '%s'", from)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid item number '%s'", to)
+			}
+		}
+		if first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("items '%s' out of range 1-%d", tok, n)
+		}
+		for i := first - 1; i < last; i++ {
+			if !seen[i] {
+				seen[i] = true
+				items = append(items, i)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no item numbers")
+	}
+	return items, nil
+}
+
+// PendingItems returns the indexes of the items pending a decision.
+func (r *Receipt) PendingItems() []int {
+	var items []int
+	for i := 0; i < r.Len(); i++ {
+		if r.IsPending(i) {
+			items = append(items, i)
+		}
+	}
+	return items
+}
+
+// MatchItems returns the indexes of the items whose names match the
+// regular expression, ignoring case.
+func (r *Receipt) MatchItems(pattern string) ([]int, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	var items []int
+	for i, item := range r.Items {
+		if re.MatchString(item.Name) {
+			items = append(items, i)
+		}
+	}
+	return items, nil
+}
+
+// AssignItems sets the owner of the items at the given indexes and returns
+// the assigned ones. Linked discounts are skipped, they follow the item they
+// discount.
+func (r *Receipt) AssignItems(items []int, owner ReceiptItemOwner) []int {
+	var assigned []int
+	for _, i := range items {
+		if r.Items[i].DiscountOf != nil {
+			continue
+		}
+		r.SetItemOwner(i, owner, nil)
+		assigned = append(assigned, i)
+	}
+	return assigned
+}
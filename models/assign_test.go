@@ -0,0 +1,90 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseItemRanges(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		s        string
+		expected []int
+		err      string
+	}{
+		{
+			name:     "single item",
+			s:        "3",
+			expected: []int{2},
+		},
+		{
+			name:     "range",
+			s:        "3-6",
+			expected: []int{2, 3, 4, 5},
+		},
+		{
+			name:     "ranges and items without repetitions",
+			s:        "8, 3-5,4 1",
+			expected: []int{7, 2, 3, 4, 0},
+		},
+		{
+			name: "out of range",
+			s:    "7-11",
+			err:  "items '7-11' out of range 1-10",
+		},
+		{
+			name: "reversed range",
+			s:    "5-3",
+			err:  "items '5-3' out of range 1-10",
+		},
+		{
+			name: "not a number",
+			s:    "3-x",
+			err:  "invalid item number 'x'",
+		},
+		{
+			name: "empty",
+			s:    " , ",
+			err:  "no item numbers",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := models.ParseItemRanges(tt.s, 10)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, items)
+		})
+	}
+}
+
+func TestMatchItems(t *testing.T) {
+	receipt := newEditReceipt()
+	items, err := receipt.MatchItems("milk")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, items)
+
+	items, err = receipt.MatchItems("^bread$")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, items)
+
+	_, err = receipt.MatchItems("(")
+	assert.Error(t, err)
+}
+
+func TestAssignItems(t *testing.T) {
+	receipt := newEditReceipt()
+	assert.Equal(t, []int{0}, receipt.PendingItems())
+
+	assigned := receipt.AssignItems([]int{0, 1, 2}, models.Shared)
+	assert.Equal(t, []int{0, 1}, assigned)
+	for _, item := range receipt.Items {
+		assert.Equal(t, models.Shared, item.Owner)
+	}
+	assert.Empty(t, receipt.PendingItems())
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type (
+	// CSVImporter imports receipts from CSV files with a header row. The
+	// columns are found by their header names, see CSVColumns.
+	CSVImporter struct {
+		Columns CSVColumns
+
+		// Delimiter is detected from the header row if empty.
+		Delimiter rune
+
+		// Currency is detected from the file if empty.
+		Currency Currency
+	}
+
+	// CSVColumns are the header names of the columns of the item fields,
+	// compared case-insensitively. Empty names fall back to common ones,
+	// like "Product" for the name or "Qty" for the quantity. Either the price
+	// or the unit price is required.
+	CSVColumns struct {
+		Name      string
+		Price     string
+		Quantity  string
+		UnitPrice string
+	}
+)
+
+var (
+	defaultCSVNameColumns      = []string{"name", "product", "item", "description", "article", "omschrijving", "artikel", "produkt"}
+	defaultCSVPriceColumns     = []string{"price", "total", "line total", "total price", "amount", "prijs", "bedrag", "totaal", "preis", "betrag"}
+	defaultCSVQuantityColumns  = []string{"quantity", "qty", "units", "aantal", "menge", "anzahl"}
+	defaultCSVUnitPriceColumns = []string{"unit price", "unit_price", "price per unit", "stukprijs", "einzelpreis"}
+)
+
+// Accepts ...
+func (c *CSVImporter) Accepts(fileName, mimeType string) bool {
+	switch mimeType {
+	case "text/csv", "text/comma-separated-values", "application/csv":
+		return true
+	}
+	return hasExtension(fileName, ".csv")
+}
+
+// Import ...
+func (c *CSVImporter) Import(b []byte) (*Receipt, error) {
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	currency := c.Currency
+	if currency == "" {
+		currency, _ = DetectCurrency(string(b))
+	}
+	p := &receiptParser{currency: currency.OrDefault()}
+
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = c.delimiter(b)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	name := c.column(header, c.Columns.Name, defaultCSVNameColumns)
+	price := c.column(header, c.Columns.Price, defaultCSVPriceColumns)
+	quantity := c.column(header, c.Columns.Quantity, defaultCSVQuantityColumns)
+	unitPrice := c.column(header, c.Columns.UnitPrice, defaultCSVUnitPriceColumns)
+	if name < 0 {
+		return nil, errors.New("CSV header has no column for the item name")
+	}
+	if price < 0 && unitPrice < 0 {
+		return nil, errors.New("CSV header has no column for the item price")
+	}
+
+	receipt := &Receipt{Currency: currency}
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(RemoveCurrencySymbols(record[i]))
+	}
+	for row := 2; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV row %d: %w", row, err)
+		}
+		item := &ReceiptItem{Name: field(record, name)}
+		if item.Name == "" {
+			continue
+		}
+		var unit PriceInCents
+		if s := field(record, unitPrice); s != "" {
+			if unit, err = c.parsePrice(p, s, row); err != nil {
+				return nil, err
+			}
+		}
+		if q, err := strconv.Atoi(field(record, quantity)); err == nil && q > 1 {
+			item.Quantity, item.UnitPrice = q, unit
+		}
+		if s := field(record, price); s != "" {
+			if item.Price, err = c.parsePrice(p, s, row); err != nil {
+				return nil, err
+			}
+		} else if item.Quantity == 0 {
+			item.Price = unit
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+	receipt.CompletePrices()
+	return receipt, nil
+}
+
+func (c *CSVImporter) parsePrice(p *receiptParser, s string, row int) (PriceInCents, error) {
+	price, ok := p.parsePrice(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid price '%s' in CSV row %d", s, row)
+	}
+	return price, nil
+}
+
+// delimiter returns the configured delimiter, or the most common of comma,
+// semicolon and tab in the header row.
+func (c *CSVImporter) delimiter(b []byte) rune {
+	if c.Delimiter != 0 {
+		return c.Delimiter
+	}
+	header := string(b)
+	if i := strings.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	delimiter, count := ',', strings.Count(header, ",")
+	for _, d := range []rune{';', '\t'} {
+		if n := strings.Count(header, string(d)); n > count {
+			delimiter, count = d, n
+		}
+	}
+	return delimiter
+}
+
+// column returns the index of the column with the configured name, or with
+// the first of the default names found in the header.
+func (c *CSVImporter) column(header []string, configured string, defaults []string) int {
+	names := defaults
+	if configured != "" {
+		names = []string{configured}
+	}
+	for _, name := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Currency is an ISO 4217 currency code.
+	Currency string
+)
+
+const (
+	// DefaultCurrency is the currency of receipts where none was detected.
+	DefaultCurrency Currency = "EUR"
+)
+
+var (
+	// minorUnits are the currencies whose minor unit is not 1/100.
+	minorUnits = map[Currency]int{
+		"BHD": 3,
+		"CLP": 0,
+		"IQD": 3,
+		"ISK": 0,
+		"JOD": 3,
+		"JPY": 0,
+		"KRW": 0,
+		"KWD": 3,
+		"LYD": 3,
+		"OMR": 3,
+		"PYG": 0,
+		"TND": 3,
+		"UGX": 0,
+		"VND": 0,
+	}
+
+	// currencySymbols maps the symbols and codes that identify a currency on
+	// a receipt. Longer symbols come first so "R$" is not mistaken for "$".
+	currencySymbols = []struct {
+		symbol   string
+		currency Currency
+	}{
+		{symbol: "R$", currency: "BRL"},
+		{symbol: "BRL", currency: "BRL"},
+		{symbol: "CHF", currency: "CHF"},
+		{symbol: "GBP", currency: "GBP"},
+		{symbol: "£", currency: "GBP"},
+		{symbol: "EUR", currency: "EUR"},
+		{symbol: "€", currency: "EUR"},
+		{symbol: "USD", currency: "USD"},
+		{symbol: "US$", currency: "USD"},
+		{symbol: "$", currency: "USD"},
+		{symbol: "JPY", currency: "JPY"},
+		{symbol: "¥", currency: "JPY"},
+	}
+
+	regexCurrencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	regexPrice        = regexp.MustCompile(`^\s*(-?)([0-9]*)(\.([0-9]+))?\s*$`)
+)
+
+// ParseCurrency parses an ISO 4217 currency code.
+func ParseCurrency(s string) (Currency, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !regexCurrencyCode.MatchString(s) {
+		return "", false
+	}
+	return Currency(s), true
+}
+
+// DetectCurrency returns the currency of the first currency symbol or code
+// found in the text.
+func DetectCurrency(text string) (Currency, bool) {
+	first, found := -1, Currency("")
+	for _, cs := range currencySymbols {
+		if idx := indexCurrencySymbol(text, cs.symbol); idx >= 0 && (first < 0 || idx < first) {
+			first, found = idx, cs.currency
+		}
+	}
+	return found, first >= 0
+}
+
+// RemoveCurrencySymbols removes all the currency symbols and codes from the
+// text, so prices like "£1.99" become plain numbers.
+func RemoveCurrencySymbols(text string) string {
+	for _, cs := range currencySymbols {
+		for idx := indexCurrencySymbol(text, cs.symbol); idx >= 0; idx = indexCurrencySymbol(text, cs.symbol) {
+			text = text[:idx] + " " + text[idx+len(cs.symbol):]
+		}
+	}
+	return text
+}
+
+// indexCurrencySymbol finds a symbol in the text. Symbols made of letters
+// must not be part of a longer word, e.g. "CHF" in "CHFX".
+func indexCurrencySymbol(text, symbol string) int {
+	isLetter := func(b byte) bool { return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') }
+	offset := 0
+	for {
+		idx := strings.Index(text[offset:], symbol)
+		if idx < 0 {
+			return -1
+		}
+		idx += offset
+		end := idx + len(symbol)
+		if !isLetter(symbol[0]) || ((idx == 0 || !isLetter(text[idx-1])) && (end == len(text) || !isLetter(text[end]))) {
+			return idx
+		}
+		offset = end
+	}
+}
+
+// MinorUnits returns the number of decimal places of the currency.
+func (c Currency) MinorUnits() int {
+	if units, ok := minorUnits[c]; ok {
+		return units
+	}
+	return 2
+}
+
+// OrDefault returns the currency or DefaultCurrency if it is empty.
+func (c Currency) OrDefault() Currency {
+	if c == "" {
+		return DefaultCurrency
+	}
+	return c
+}
+
+// ParsePrice parses a price with at most as many decimal places as the
+// currency has.
+func (c Currency) ParsePrice(tok string) (PriceInCents, bool) {
+	m := regexPrice.FindStringSubmatch(tok)
+	if m == nil || (m[2] == "" && m[4] == "") {
+		return 0, false
+	}
+	sign, majorStr, minorStr := m[1], m[2], m[4]
+	units := c.MinorUnits()
+	if len(minorStr) > units || (m[3] != "" && units == 0) {
+		return 0, false
+	}
+	major, _ := strconv.ParseInt(majorStr, 10, 64)
+	minorStr += strings.Repeat("0", units-len(minorStr))
+	minor, _ := strconv.ParseInt(minorStr, 10, 64)
+	price := PriceInCents(major*pow10(units) + minor)
+	if sign == "-" {
+		price = -price
+	}
+	return price, true
+}
+
+// Format formats a price with the decimal places of the currency.
+func (c Currency) Format(p PriceInCents) string {
+	units := c.MinorUnits()
+	i := int64(p)
+	var s string
+	if i < 0 {
+		i = -i
+		s += "-"
+	}
+	scale := pow10(units)
+	s += fmt.Sprintf("%d", i/scale)
+	if units > 0 {
+		s += fmt.Sprintf(".%0*d", units, i%scale)
+	}
+	return s
+}
+
+// Convert converts a price in the currency to the given currency using
+// rate, the amount of the given currency that one unit of this currency is
+// worth. The result is rounded half away from zero.
+func (c Currency) Convert(p PriceInCents, to Currency, rate *big.Rat) PriceInCents {
+	v := new(big.Rat).SetInt64(int64(p))
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetFrac64(pow10(to.MinorUnits()), pow10(c.MinorUnits())))
+	return roundRat(v)
+}
+
+func roundRat(v *big.Rat) PriceInCents {
+	num, den := new(big.Int).Abs(v.Num()), v.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return PriceInCents(q.Int64())
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,130 @@
+package models_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrencyParsePrice(t *testing.T) {
+	for _, tt := range []struct {
+		currency models.Currency
+		text     string
+		expected models.PriceInCents
+		ok       bool
+	}{
+		{currency: "EUR", text: "1.99", expected: 199, ok: true},
+		{currency: "EUR", text: "-.5", expected: -50, ok: true},
+		{currency: "EUR", text: "-4", expected: -400, ok: true},
+		{currency: "EUR", text: ".4", expected: 40, ok: true},
+		{currency: "EUR", text: "-0.1", expected: -10, ok: true},
+		{currency: "EUR", text: "1.999", ok: false},
+		{currency: "JPY", text: "450", expected: 450, ok: true},
+		{currency: "JPY", text: "4.50", ok: false},
+		{currency: "BHD", text: "1.25", expected: 1250, ok: true},
+		{currency: "BHD", text: "1.255", expected: 1255, ok: true},
+		{currency: "EUR", text: "abc", ok: false},
+	} {
+		t.Run(string(tt.currency)+" "+tt.text, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			actual, ok := tt.currency.ParsePrice(tt.text)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCurrencyFormat(t *testing.T) {
+	for _, tt := range []struct {
+		currency models.Currency
+		price    models.PriceInCents
+		expected string
+	}{
+		{currency: "EUR", price: 199, expected: "1.99"},
+		{currency: "EUR", price: -5, expected: "-0.05"},
+		{currency: "JPY", price: 450, expected: "450"},
+		{currency: "BHD", price: 1255, expected: "1.255"},
+	} {
+		t.Run(tt.expected, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, tt.currency.Format(tt.price))
+		})
+	}
+}
+
+func TestDetectCurrency(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected models.Currency
+		ok       bool
+	}{
+		{text: "Milk £1.20", expected: "GBP", ok: true},
+		{text: "Pão R$ 5.50 Café $ 2.00", expected: "BRL", ok: true},
+		{text: "Cheese 4.50 CHF", expected: "CHF", ok: true},
+		{text: "EURO SPAR 1.99", ok: false},
+		{text: "Milk 1.20", ok: false},
+	} {
+		t.Run(tt.text, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			actual, ok := models.DetectCurrency(tt.text)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestParseReceiptCurrency(t *testing.T) {
+	receipt, unparsed := models.ParseReceipt("Milk £1.20 Bread £0.95")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, models.Currency("GBP"), receipt.Currency)
+	assert.Equal(t, []*models.ReceiptItem{
+		{Name: "Milk", Price: 120},
+		{Name: "Bread", Price: 95},
+	}, receipt.Items)
+}
+
+func TestExpenseConvert(t *testing.T) {
+	expense := &models.Expense{
+		Cost:     1000,
+		Currency: "GBP",
+		UserShares: []*models.UserShare{
+			{User: "a", Paid: 0, Owed: 333},
+			{User: "m", Paid: 0, Owed: 333},
+			{User: "j", Paid: 1000, Owed: 334},
+		},
+		Description: "shared",
+	}
+	converted := expense.Convert("EUR", big.NewRat(117, 100))
+	assert.Equal(t, &models.Expense{
+		Cost:     1170,
+		Currency: "EUR",
+		UserShares: []*models.UserShare{
+			{User: "a", Paid: 0, Owed: 390},
+			{User: "m", Paid: 0, Owed: 389},
+			{User: "j", Paid: 1170, Owed: 391},
+		},
+		Description: "shared",
+	}, converted)
+}
+
+func TestUnmarshalLegacyReceipt(t *testing.T) {
+	var receipt *models.Receipt
+	err := json.Unmarshal([]byte(`[{"name":"Milk","euro_cents":120},{"name":"Cola","quantity":2,"unit_euro_cents":150,"euro_cents":300}]`), &receipt)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Receipt{
+		Currency: "EUR",
+		Items: []*models.ReceiptItem{
+			{Name: "Milk", Price: 120},
+			{Name: "Cola", Quantity: 2, UnitPrice: 150, Price: 300},
+		},
+	}, receipt)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LinkDiscounts links every unlinked negative item to the preceding
+// positive item with the longest name that is a prefix of the discount name,
+// e.g. "Smoky BBQ wings Discount" is linked to "Smoky BBQ wings". Ties go to
+// the closest item. Returns the indexes of the discounts that were linked.
+func (r *Receipt) LinkDiscounts() (linked []int) {
+	for i, discount := range r.Items {
+		if discount.Price >= 0 || discount.DiscountOf != nil {
+			continue
+		}
+		discountName := strings.ToLower(strings.TrimSpace(discount.Name))
+		best, bestLen := -1, 0
+		for j := i - 1; j >= 0; j-- {
+			item := r.Items[j]
+			itemName := strings.ToLower(strings.TrimSpace(item.Name))
+			if item.Price > 0 && item.DiscountOf == nil && len(itemName) > bestLen &&
+				itemName != discountName && strings.HasPrefix(discountName, itemName) {
+				best, bestLen = j, len(itemName)
+			}
+		}
+		if best >= 0 {
+			r.linkDiscount(i, best)
+			linked = append(linked, i)
+		}
+	}
+	return
+}
+
+// LinkDiscount links the negative item at index discount to the positive
+// item at index item, so the discount inherits the owner of the item.
+func (r *Receipt) LinkDiscount(discount, item int) error {
+	if discount < 0 || discount >= r.Len() || item < 0 || item >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	if discount == item {
+		return errors.New("an item cannot discount itself")
+	}
+	if r.Items[discount].Price >= 0 {
+		return fmt.Errorf("'%s' is not a discount", r.Items[discount].Name)
+	}
+	if r.Items[item].Price <= 0 || r.Items[item].DiscountOf != nil {
+		return fmt.Errorf("'%s' cannot be discounted", r.Items[item].Name)
+	}
+	r.linkDiscount(discount, item)
+	return nil
+}
+
+func (r *Receipt) linkDiscount(discount, item int) {
+	idx := item
+	r.Items[discount].DiscountOf = &idx
+	r.Items[discount].Owner = r.Items[item].Owner
+	r.Items[discount].Weights = r.Items[item].Weights
+}
+
+// UnlinkDiscount removes the link of the item at index discount and clears
+// its owner so it has to be decided again.
+func (r *Receipt) UnlinkDiscount(discount int) error {
+	if discount < 0 || discount >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	if r.Items[discount].DiscountOf == nil {
+		return fmt.Errorf("'%s' is not linked to any item", r.Items[discount].Name)
+	}
+	r.Items[discount].DiscountOf = nil
+	r.Items[discount].SetOwner("")
+	return nil
+}
+
+// SetItemOwner sets the owner and weights of the item at index i and of the
+// discounts linked to it.
+func (r *Receipt) SetItemOwner(i int, owner ReceiptItemOwner, weights ReceiptItemWeights) {
+	r.Items[i].SetOwner(owner)
+	r.Items[i].Weights = weights
+	for _, discount := range r.LinkedDiscounts(i) {
+		r.Items[discount].SetOwner(owner)
+		r.Items[discount].Weights = weights
+	}
+}
+
+// LinkedDiscounts returns the indexes of the discounts linked to the item
+// at index i.
+func (r *Receipt) LinkedDiscounts(i int) (discounts []int) {
+	for j, item := range r.Items {
+		if item.DiscountOf != nil && *item.DiscountOf == i {
+			discounts = append(discounts, j)
+		}
+	}
+	return
+}
+
+// IsPending tells whether the item at index i still needs an owner
+// decision. Linked discounts never do, they follow the item they discount.
+func (r *Receipt) IsPending(i int) bool {
+	return r.Items[i].Owner == "" && r.Items[i].DiscountOf == nil
+}
+
+// allocateDiscounts returns the final total of each owner after moving the
+// amounts that cannot stand on their own into the totals of other owners:
+//
+//  1. A negative total of items shared by some members is spread across the
+//     positive totals of the owners formed only by those same members, in
+//     proportion to these totals.
+//  2. The total of the items spread over the whole receipt is spread across
+//     all the positive totals, in proportion to these totals.
+//
+// A negative amount is spread only up to the sum of the totals absorbing it,
+// so no total becomes negative. The amounts that could not be spread are
+// returned as leftovers by owner.
+func allocateDiscounts(ownerTotals map[ReceiptItemOwner]PriceInCents,
+	members []ReceiptItemOwner) (finalTotals, leftovers map[ReceiptItemOwner]PriceInCents) {
+	finalTotals = make(map[ReceiptItemOwner]PriceInCents)
+	for owner, total := range ownerTotals {
+		if owner != WholeReceipt {
+			finalTotals[owner] = total
+		}
+	}
+
+	// spread returns the part of amount that could not be spread
+	spread := func(amount PriceInCents, accept func(owner ReceiptItemOwner) bool) PriceInCents {
+		var owners []ReceiptItemOwner
+		var weights []int64
+		var absorbable PriceInCents
+		for _, owner := range sortedOwners(finalTotals) {
+			if total := finalTotals[owner]; total > 0 && accept(owner) {
+				owners = append(owners, owner)
+				weights = append(weights, int64(total))
+				absorbable += total
+			}
+		}
+		if len(owners) == 0 {
+			return amount
+		}
+		var leftover PriceInCents
+		if amount < -absorbable {
+			amount, leftover = -absorbable, amount+absorbable
+		}
+		for i, share := range allocate(amount, weights) {
+			finalTotals[owners[i]] += share
+		}
+		return leftover
+	}
+
+	leftovers = make(map[ReceiptItemOwner]PriceInCents)
+	for _, owner := range sortedOwners(finalTotals) {
+		total := finalTotals[owner]
+		if total >= 0 || !owner.IsShared(members) {
+			continue
+		}
+		subset := owner.Members(members)
+		finalTotals[owner] = 0
+		if leftover := spread(total, func(o ReceiptItemOwner) bool { return o.Subset(members, subset) }); leftover != 0 {
+			leftovers[owner] = leftover
+		}
+	}
+
+	if total := ownerTotals[WholeReceipt]; total != 0 {
+		if leftover := spread(total, func(ReceiptItemOwner) bool { return true }); leftover != 0 {
+			leftovers[WholeReceipt] = leftover
+		}
+	}
+
+	return
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RenameItem renames the item at index i.
+func (r *Receipt) RenameItem(i int, name string) error {
+	if i < 0 || i >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the name cannot be empty")
+	}
+	r.Items[i].Name = name
+	return nil
+}
+
+// InsertItem inserts the item at index i, moving the items from i on one
+// position down.
+func (r *Receipt) InsertItem(i int, item *ReceiptItem) error {
+	if i < 0 || i > r.Len() {
+		return errors.New("item number out of range")
+	}
+	for _, old := range r.Items {
+		if old.DiscountOf != nil && *old.DiscountOf >= i {
+			discountOf := *old.DiscountOf + 1
+			old.DiscountOf = &discountOf
+		}
+	}
+	r.Items = append(r.Items[:i], append([]*ReceiptItem{item}, r.Items[i:]...)...)
+	return nil
+}
+
+// DeleteItem deletes the item at index i. The discounts linked to it are
+// unlinked and have to be decided again.
+func (r *Receipt) DeleteItem(i int) error {
+	if i < 0 || i >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	if r.Len() == 1 {
+		return errors.New("the receipt must have at least one item")
+	}
+	for _, old := range r.Items {
+		switch {
+		case old.DiscountOf == nil:
+		case *old.DiscountOf == i:
+			old.DiscountOf = nil
+			old.SetOwner("")
+		case *old.DiscountOf > i:
+			discountOf := *old.DiscountOf - 1
+			old.DiscountOf = &discountOf
+		}
+	}
+	r.Items = append(r.Items[:i], r.Items[i+1:]...)
+	return nil
+}
+
+// SplitLine moves part of the price of the item at index i to a new item
+// right after it, named like the item if name is empty. The new item has to
+// be decided, and the item loses its units since they no longer match its
+// price.
+func (r *Receipt) SplitLine(i int, price PriceInCents, name string) error {
+	if i < 0 || i >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	item := r.Items[i]
+	if price == 0 || (price > 0) != (item.Price > 0) || abs(price) >= abs(item.Price) {
+		return fmt.Errorf("the price of the new line must be between zero and the price of '%s'", item.Name)
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = item.Name
+	}
+	if err := r.InsertItem(i+1, &ReceiptItem{Name: name, Price: price}); err != nil {
+		return err
+	}
+	item.Price -= price
+	item.Quantity = 0
+	item.UnitPrice = 0
+	return nil
+}
+
+func abs(p PriceInCents) PriceInCents {
+	if p < 0 {
+		return -p
+	}
+	return p
+}
@@ -0,0 +1,71 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEditReceipt() *models.Receipt {
+	discountOf := 1
+	return &models.Receipt{Items: []*models.ReceiptItem{
+		{Name: "Bread", Price: 95},
+		{Name: "Oat Milk", Price: 398, Quantity: 2, UnitPrice: 199, Owner: "a"},
+		{Name: "Oat Milk Discount", Price: -50, DiscountOf: &discountOf, Owner: "a"},
+	}}
+}
+
+func TestRenameItem(t *testing.T) {
+	receipt := newEditReceipt()
+	require.NoError(t, receipt.RenameItem(0, " Sourdough Bread "))
+	assert.Equal(t, "Sourdough Bread", receipt.Items[0].Name)
+	assert.Error(t, receipt.RenameItem(0, " "))
+	assert.Error(t, receipt.RenameItem(3, "Eggs"))
+}
+
+func TestInsertItem(t *testing.T) {
+	receipt := newEditReceipt()
+	require.NoError(t, receipt.InsertItem(1, &models.ReceiptItem{Name: "Eggs", Price: 250}))
+	require.Equal(t, 4, receipt.Len())
+	assert.Equal(t, "Eggs", receipt.Items[1].Name)
+	assert.Equal(t, 2, *receipt.Items[3].DiscountOf)
+	assert.True(t, receipt.IsPending(1))
+
+	require.NoError(t, receipt.InsertItem(4, &models.ReceiptItem{Name: "Bag", Price: 10}))
+	assert.Equal(t, "Bag", receipt.Items[4].Name)
+	assert.Error(t, receipt.InsertItem(6, &models.ReceiptItem{Name: "Bag", Price: 10}))
+}
+
+func TestDeleteItem(t *testing.T) {
+	receipt := newEditReceipt()
+	require.NoError(t, receipt.DeleteItem(0))
+	require.Equal(t, 2, receipt.Len())
+	assert.Equal(t, 0, *receipt.Items[1].DiscountOf)
+
+	require.NoError(t, receipt.DeleteItem(0))
+	require.Equal(t, 1, receipt.Len())
+	assert.Nil(t, receipt.Items[0].DiscountOf)
+	assert.True(t, receipt.IsPending(0), "unlinked discounts must be decided again")
+
+	assert.Error(t, receipt.DeleteItem(0))
+	assert.Error(t, receipt.DeleteItem(1))
+}
+
+func TestSplitLine(t *testing.T) {
+	receipt := newEditReceipt()
+	require.NoError(t, receipt.SplitLine(1, 150, "Soy Milk"))
+	require.Equal(t, 4, receipt.Len())
+	assert.Equal(t, &models.ReceiptItem{Name: "Oat Milk", Price: 248, Owner: "a"}, receipt.Items[1])
+	assert.Equal(t, &models.ReceiptItem{Name: "Soy Milk", Price: 150}, receipt.Items[2])
+	assert.Equal(t, 1, *receipt.Items[3].DiscountOf)
+
+	require.NoError(t, receipt.SplitLine(0, 45, ""))
+	assert.Equal(t, "Bread", receipt.Items[1].Name)
+	assert.Equal(t, models.PriceInCents(50), receipt.Items[0].Price)
+
+	for _, price := range []models.PriceInCents{0, -10, 50, 60} {
+		assert.Error(t, receipt.SplitLine(0, price, ""), price)
+	}
+}
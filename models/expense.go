@@ -1,10 +1,18 @@
 package models
 
+import (
+	"math/big"
+	"time"
+)
+
 type (
 	// Expense ...
 	Expense struct {
-		Cost        PriceInCents
-		UserShares  [2]*UserShare
+		Cost     PriceInCents
+		Currency Currency
+		// Date is when the expense happened, zero if unknown.
+		Date        time.Time
+		UserShares  []*UserShare
 		Description string
 	}
 
@@ -15,3 +23,39 @@ type (
 		Owed PriceInCents
 	}
 )
+
+// Convert returns the expense converted to the given currency using rate,
+// the amount of the given currency that one unit of the expense currency is
+// worth. The paid and owed shares are split in proportion to the original
+// ones, so they still add up to the converted cost.
+func (e *Expense) Convert(to Currency, rate *big.Rat) *Expense {
+	cost := e.Currency.Convert(e.Cost, to, rate)
+	paid := make([]*big.Rat, len(e.UserShares))
+	owed := make([]*big.Rat, len(e.UserShares))
+	for i, share := range e.UserShares {
+		paid[i] = scaleShare(share.Paid, e.Cost, cost)
+		owed[i] = scaleShare(share.Owed, e.Cost, cost)
+	}
+	converted := &Expense{
+		Cost:        cost,
+		Currency:    to,
+		Date:        e.Date,
+		Description: e.Description,
+	}
+	roundedPaid, roundedOwed := roundShares(paid), roundShares(owed)
+	for i, share := range e.UserShares {
+		converted.UserShares = append(converted.UserShares, &UserShare{
+			User: share.User,
+			Paid: roundedPaid[i],
+			Owed: roundedOwed[i],
+		})
+	}
+	return converted
+}
+
+func scaleShare(share, from, to PriceInCents) *big.Rat {
+	if from == 0 {
+		return new(big.Rat)
+	}
+	return big.NewRat(int64(share)*int64(to), int64(from))
+}
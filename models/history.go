@@ -0,0 +1,226 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type (
+	// History is the log of the operations applied to a receipt, for undo
+	// and redo.
+	History struct {
+		Done   []*Operation `json:"done,omitempty"`
+		Undone []*Operation `json:"undone,omitempty"`
+	}
+
+	// Operation is a change of a receipt, stored as the delta between the
+	// receipt before and after it, so it can be undone and redone.
+	Operation struct {
+		Description string    `json:"description"`
+		Time        time.Time `json:"time"`
+		// Item is the item being decided before the operation.
+		Item int `json:"item"`
+		// Changes are the changes of the items, applied in order.
+		Changes []*ItemChange `json:"changes,omitempty"`
+		// Header is the change of the other fields of the receipt, if any.
+		Header *HeaderChange `json:"header,omitempty"`
+	}
+
+	// ItemChange replaces the item at Index. Old is nil for an inserted
+	// item, and New is nil for a deleted one.
+	ItemChange struct {
+		Index int          `json:"index"`
+		Old   *ReceiptItem `json:"old,omitempty"`
+		New   *ReceiptItem `json:"new,omitempty"`
+	}
+
+	// HeaderChange holds the fields of the receipt other than its items,
+	// like the currency, before and after an operation, without items and
+	// history.
+	HeaderChange struct {
+		Old *Receipt `json:"old"`
+		New *Receipt `json:"new"`
+	}
+)
+
+// Clone returns a deep copy of the receipt without its history.
+func (r *Receipt) Clone() (*Receipt, error) {
+	history := r.History
+	r.History = nil
+	defer func() { r.History = history }()
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling receipt: %w", err)
+	}
+	var clone *Receipt
+	if err := json.Unmarshal(b, &clone); err != nil {
+		return nil, fmt.Errorf("error unmarshaling receipt: %w", err)
+	}
+	return clone, nil
+}
+
+// Record adds an operation to the history, given a clone of the receipt and
+// the item being decided before it, and discards the undone operations.
+func (r *Receipt) Record(description string, item int, before *Receipt) {
+	if r.History == nil {
+		r.History = &History{}
+	}
+	r.History.Done = append(r.History.Done, &Operation{
+		Description: description,
+		Time:        time.Now(),
+		Item:        item,
+		Changes:     diffItems(before.Items, r.Items),
+		Header:      diffHeader(before, r),
+	})
+	r.History.Undone = nil
+}
+
+// CanUndo ...
+func (r *Receipt) CanUndo() bool {
+	return r.History != nil && len(r.History.Done) > 0
+}
+
+// CanRedo ...
+func (r *Receipt) CanRedo() bool {
+	return r.History != nil && len(r.History.Undone) > 0
+}
+
+// Undo restores the receipt before the last operation and returns it.
+func (r *Receipt) Undo() (*Operation, bool) {
+	if !r.CanUndo() {
+		return nil, false
+	}
+	op := r.History.Done[len(r.History.Done)-1]
+	r.History.Done = r.History.Done[:len(r.History.Done)-1]
+	for i := len(op.Changes) - 1; i >= 0; i-- {
+		c := op.Changes[i]
+		r.applyItemChange(c.Index, c.New, c.Old)
+	}
+	if op.Header != nil {
+		r.applyHeader(op.Header.Old)
+	}
+	r.History.Undone = append(r.History.Undone, op)
+	return op, true
+}
+
+// Redo applies again the last undone operation and returns it.
+func (r *Receipt) Redo() (*Operation, bool) {
+	if !r.CanRedo() {
+		return nil, false
+	}
+	op := r.History.Undone[len(r.History.Undone)-1]
+	r.History.Undone = r.History.Undone[:len(r.History.Undone)-1]
+	for _, c := range op.Changes {
+		r.applyItemChange(c.Index, c.Old, c.New)
+	}
+	if op.Header != nil {
+		r.applyHeader(op.Header.New)
+	}
+	r.History.Done = append(r.History.Done, op)
+	return op, true
+}
+
+// applyItemChange replaces the item from with a copy of the item to at
+// index i, inserting or deleting it if from or to is nil.
+func (r *Receipt) applyItemChange(i int, from, to *ReceiptItem) {
+	switch {
+	case from == nil:
+		r.Items = append(r.Items[:i], append([]*ReceiptItem{to.clone()}, r.Items[i:]...)...)
+	case to == nil:
+		r.Items = append(r.Items[:i], r.Items[i+1:]...)
+	default:
+		r.Items[i] = to.clone()
+	}
+}
+
+// applyHeader sets the fields of the receipt other than its items and
+// history to the ones of header.
+func (r *Receipt) applyHeader(header *Receipt) {
+	items, history := r.Items, r.History
+	*r = *header.header()
+	r.Items, r.History = items, history
+}
+
+// diffItems returns the changes turning the items before into the items
+// after. The common items at the start and at the end are matched ignoring
+// DiscountOf, which shifts when items are inserted or deleted, so only the
+// items in between are inserted or deleted.
+func diffItems(before, after []*ReceiptItem) []*ItemChange {
+	sameItem := func(a, b *ReceiptItem) bool {
+		x, y := *a, *b
+		x.DiscountOf, y.DiscountOf = nil, nil
+		return reflect.DeepEqual(x, y)
+	}
+	m, n := len(before), len(after)
+	prefix := 0
+	for prefix < m && prefix < n && sameItem(before[prefix], after[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < m-prefix && suffix < n-prefix && sameItem(before[m-1-suffix], after[n-1-suffix]) {
+		suffix++
+	}
+
+	var changes []*ItemChange
+	replace := func(i int, oldItem, newItem *ReceiptItem) {
+		if !reflect.DeepEqual(oldItem, newItem) {
+			changes = append(changes, &ItemChange{Index: i, Old: oldItem, New: newItem.clone()})
+		}
+	}
+	for i := 0; i < prefix; i++ {
+		replace(i, before[i], after[i])
+	}
+	middle := prefix
+	for ; middle < m-suffix && middle < n-suffix; middle++ {
+		replace(middle, before[middle], after[middle])
+	}
+	for i := middle; i < m-suffix; i++ {
+		changes = append(changes, &ItemChange{Index: middle, Old: before[i]})
+	}
+	for i := middle; i < n-suffix; i++ {
+		changes = append(changes, &ItemChange{Index: i, New: after[i].clone()})
+	}
+	for i := 0; i < suffix; i++ {
+		replace(n-suffix+i, before[m-suffix+i], after[n-suffix+i])
+	}
+	return changes
+}
+
+// diffHeader returns the change of the fields of the receipt other than its
+// items and history, if any.
+func diffHeader(before, after *Receipt) *HeaderChange {
+	oldHeader, newHeader := before.header(), after.header()
+	if reflect.DeepEqual(oldHeader, newHeader) {
+		return nil
+	}
+	return &HeaderChange{Old: oldHeader, New: newHeader}
+}
+
+// header returns a copy of the receipt without items and history.
+func (r *Receipt) header() *Receipt {
+	h := *r
+	h.Items, h.History = nil, nil
+	if r.Total != nil {
+		total := *r.Total
+		h.Total = &total
+	}
+	return &h
+}
+
+// clone returns a deep copy of the item.
+func (item *ReceiptItem) clone() *ReceiptItem {
+	c := *item
+	if item.DiscountOf != nil {
+		discountOf := *item.DiscountOf
+		c.DiscountOf = &discountOf
+	}
+	if item.Weights != nil {
+		c.Weights = make(ReceiptItemWeights, len(item.Weights))
+		for owner, weight := range item.Weights {
+			c.Weights[owner] = weight
+		}
+	}
+	return &c
+}
@@ -0,0 +1,149 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	receipt := newEditReceipt()
+	assert.False(t, receipt.CanUndo())
+	_, ok := receipt.Undo()
+	assert.False(t, ok)
+
+	before := mustClone(t, receipt)
+	receipt.SetItemOwner(0, "m", nil)
+	receipt.Record("owner of item 1", 0, before)
+
+	before = mustClone(t, receipt)
+	receipt.Items[1].Price = 300
+	receipt.Record("price of item 2", 1, before)
+	require.Len(t, receipt.History.Done, 2)
+	require.Len(t, receipt.History.Done[1].Changes, 1)
+	assert.Equal(t, 1, receipt.History.Done[1].Changes[0].Index)
+
+	op, ok := receipt.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "price of item 2", op.Description)
+	assert.Equal(t, 1, op.Item)
+	assert.Equal(t, models.PriceInCents(398), receipt.Items[1].Price)
+	assert.True(t, receipt.CanRedo())
+
+	_, ok = receipt.Undo()
+	require.True(t, ok)
+	assert.True(t, receipt.IsPending(0))
+	assert.False(t, receipt.CanUndo())
+
+	op, ok = receipt.Redo()
+	require.True(t, ok)
+	assert.Equal(t, "owner of item 1", op.Description)
+	assert.Equal(t, models.ReceiptItemOwner("m"), receipt.Items[0].Owner)
+	assert.Len(t, receipt.History.Undone, 1)
+
+	// the history keeps its own copies of the items
+	receipt.Items[0].Owner = "a"
+	_, ok = receipt.Undo()
+	require.True(t, ok)
+	_, ok = receipt.Redo()
+	require.True(t, ok)
+	assert.Equal(t, models.ReceiptItemOwner("m"), receipt.Items[0].Owner)
+
+	before = mustClone(t, receipt)
+	require.NoError(t, receipt.DeleteItem(0))
+	receipt.Record("delete item 1", 0, before)
+	assert.False(t, receipt.CanRedo(), "a new operation discards the undone ones")
+
+	_, ok = receipt.Undo()
+	require.True(t, ok)
+	assert.Equal(t, 3, receipt.Len())
+	assert.Equal(t, 1, *receipt.Items[2].DiscountOf)
+}
+
+func TestHistoryDeltas(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		change  func(t *testing.T, r *models.Receipt)
+		changes int
+	}{
+		{
+			name:    "owner",
+			change:  func(t *testing.T, r *models.Receipt) { r.SetItemOwner(0, "s", nil) },
+			changes: 1,
+		},
+		{
+			name: "owners of distant items",
+			change: func(t *testing.T, r *models.Receipt) {
+				r.AssignItems([]int{0, 1}, "m")
+			},
+			changes: 3, // the discount follows item 2
+		},
+		{
+			name: "name",
+			change: func(t *testing.T, r *models.Receipt) {
+				require.NoError(t, r.RenameItem(1, "Soy Milk"))
+			},
+			changes: 1,
+		},
+		{
+			name: "inserted item",
+			change: func(t *testing.T, r *models.Receipt) {
+				require.NoError(t, r.InsertItem(0, &models.ReceiptItem{Name: "Eggs", Price: 250}))
+			},
+			changes: 2,
+		},
+		{
+			name: "split line",
+			change: func(t *testing.T, r *models.Receipt) {
+				require.NoError(t, r.SplitLine(0, 45, "Butter"))
+			},
+			changes: 3, // the discount link shifts
+		},
+		{
+			name: "deleted discounted item",
+			change: func(t *testing.T, r *models.Receipt) {
+				require.NoError(t, r.DeleteItem(1))
+			},
+		},
+		{
+			name: "currency and total",
+			change: func(t *testing.T, r *models.Receipt) {
+				r.Currency = "GBP"
+				r.TotalOverridden = true
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := newEditReceipt()
+			receipt.Currency = "EUR"
+			original := mustClone(t, receipt)
+
+			before := mustClone(t, receipt)
+			tt.change(t, receipt)
+			changed := mustClone(t, receipt)
+			receipt.Record(tt.name, 0, before)
+			op := receipt.History.Done[0]
+			if tt.changes > 0 {
+				assert.Len(t, op.Changes, tt.changes)
+			}
+			assert.Less(t, len(op.Changes), receipt.Len()+2, "only the changed items are recorded")
+
+			_, ok := receipt.Undo()
+			require.True(t, ok)
+			assert.Equal(t, original, mustClone(t, receipt))
+
+			_, ok = receipt.Redo()
+			require.True(t, ok)
+			assert.Equal(t, changed, mustClone(t, receipt))
+		})
+	}
+}
+
+func mustClone(t *testing.T, r *models.Receipt) *models.Receipt {
+	t.Helper()
+	clone, err := r.Clone()
+	require.NoError(t, err)
+	return clone
+}
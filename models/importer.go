@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// ReceiptImporter imports receipts from files, like the CSV or JSON
+	// exports of online grocery orders.
+	ReceiptImporter interface {
+		// Accepts tells whether the importer understands the file, given its
+		// name and MIME type.
+		Accepts(fileName, mimeType string) bool
+		Import(b []byte) (*Receipt, error)
+	}
+
+	// ReceiptImporters is a registry of receipt importers. The first one
+	// accepting a file imports it.
+	ReceiptImporters []ReceiptImporter
+
+	// JSONImporter imports receipts in the JSON format returned by OpenAI,
+	// including the previous format, a plain array of items in euros.
+	JSONImporter struct{}
+)
+
+var (
+	// ErrUnsupportedFile ...
+	ErrUnsupportedFile = errors.New("unsupported file")
+)
+
+// Register adds an importer to the registry.
+func (r *ReceiptImporters) Register(importer ReceiptImporter) {
+	*r = append(*r, importer)
+}
+
+// Import imports the receipt with the first importer accepting the file.
+func (r ReceiptImporters) Import(fileName, mimeType string, b []byte) (*Receipt, error) {
+	for _, importer := range r {
+		if importer.Accepts(fileName, mimeType) {
+			return importer.Import(b)
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s' (%s)", ErrUnsupportedFile, fileName, mimeType)
+}
+
+// Accepts ...
+func (JSONImporter) Accepts(fileName, mimeType string) bool {
+	return hasExtension(fileName, ".json") || mimeType == "application/json"
+}
+
+// Import ...
+func (JSONImporter) Import(b []byte) (*Receipt, error) {
+	var receipt *Receipt
+	if err := json.Unmarshal(b, &receipt); err != nil {
+		return nil, fmt.Errorf("error unmarshaling receipt: %w", err)
+	}
+	if receipt == nil {
+		receipt = &Receipt{}
+	}
+	receipt.CompletePrices()
+	return receipt, nil
+}
+
+func hasExtension(fileName string, extensions ...string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
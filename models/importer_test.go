@@ -0,0 +1,139 @@
+package models_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptImporters(t *testing.T) {
+	var importers models.ReceiptImporters
+	importers.Register(&models.CSVImporter{})
+	importers.Register(models.JSONImporter{})
+
+	for _, tt := range []struct {
+		name     string
+		fileName string
+		mimeType string
+		content  string
+		expected *models.Receipt
+		err      string
+	}{
+		{
+			name:     "CSV with default columns",
+			fileName: "order.csv",
+			content:  "Product,Qty,Unit Price,Total\nMilk,1,1.20,1.20\nYogurt,3,0.50,1.50\n,,,\n",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Milk", Price: 120},
+				&models.ReceiptItem{Name: "Yogurt", Price: 150, Quantity: 3, UnitPrice: 50},
+			),
+		},
+		{
+			name:     "CSV with semicolons, comma decimals and symbols",
+			fileName: "bestelling.CSV",
+			content:  "\ufeffOmschrijving;Aantal;Prijs\nKaas;1;€ 4,50\nKorting;1;-1,00\n",
+			expected: &models.Receipt{
+				Currency: "EUR",
+				Items: []*models.ReceiptItem{
+					{Name: "Kaas", Price: 450},
+					{Name: "Korting", Price: -100},
+				},
+			},
+		},
+		{
+			name:     "CSV with unit prices only",
+			mimeType: "text/csv",
+			content:  "name,quantity,unit price\nApples,4,0.30\nBread,1,0.95\n",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Apples", Price: 120, Quantity: 4, UnitPrice: 30},
+				&models.ReceiptItem{Name: "Bread", Price: 95},
+			),
+		},
+		{
+			name:     "CSV without price column",
+			fileName: "order.csv",
+			content:  "name,weight\nApples,1kg\n",
+			err:      "CSV header has no column for the item price",
+		},
+		{
+			name:     "CSV with invalid price",
+			fileName: "order.csv",
+			content:  "name,price\nApples,cheap\n",
+			err:      "invalid price 'cheap' in CSV row 2",
+		},
+		{
+			name:     "legacy JSON",
+			fileName: "receipt.json",
+			content:  `[{"name":"Milk","euro_cents":120},{"name":"Cola","quantity":2,"unit_euro_cents":150}]`,
+			expected: &models.Receipt{
+				Currency: "EUR",
+				Items: []*models.ReceiptItem{
+					{Name: "Milk", Price: 120},
+					{Name: "Cola", Price: 300, Quantity: 2, UnitPrice: 150},
+				},
+			},
+		},
+		{
+			name:     "JSON object",
+			mimeType: "application/json",
+			content:  `{"currency":"GBP","store":"Tesco","items":[{"name":"Milk","price":120}]}`,
+			expected: &models.Receipt{
+				Currency: "GBP",
+				Store:    "Tesco",
+				Items:    []*models.ReceiptItem{{Name: "Milk", Price: 120}},
+			},
+		},
+		{
+			name:     "unsupported file",
+			fileName: "receipt.xlsx",
+			err:      "unsupported file: 'receipt.xlsx' ()",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			receipt, err := importers.Import(tt.fileName, tt.mimeType, []byte(tt.content))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, receipt)
+		})
+	}
+}
+
+func TestPDFImporter(t *testing.T) {
+	// the stub prints the text of the receipt like pdftotext -layout does
+	stub := filepath.Join(t.TempDir(), "pdftotext")
+	script := `#!/bin/sh
+printf 'ALBERT HEIJN\n\nMelk halfvol              1,19\nKaas jong belegen         4,50\nKorting kaas             -0,50\nAppels   4 x 0,30         1,20\n\fTOTAAL                   €6,39\nPIN                       6,39\n\f'
+`
+	require.NoError(t, os.WriteFile(stub, []byte(script), 0o755))
+	b, err := os.ReadFile("../pkg/pdf/testdata/scan.pdf")
+	require.NoError(t, err)
+	receipt, err := models.PDFImporter{TextExtractor: stub}.Import(b)
+	assert.NoError(t, err)
+	total := models.PriceInCents(639)
+	assert.Equal(t, &models.Receipt{
+		Currency: "EUR",
+		Items: []*models.ReceiptItem{
+			{Name: "Melk halfvol", Price: 119},
+			{Name: "Kaas jong belegen", Price: 450},
+			{Name: "Korting kaas", Price: -50},
+			{Name: "Appels", Price: 120, Quantity: 4, UnitPrice: 30},
+		},
+		Total:         &total,
+		PaymentMethod: "pin",
+	}, receipt)
+
+	// a scanned receipt has only form feeds between its pages
+	require.NoError(t, os.WriteFile(stub, []byte("#!/bin/sh\nprintf '\\f'\n"), 0o755))
+	_, err = models.PDFImporter{TextExtractor: stub}.Import(b)
+	assert.ErrorIs(t, err, models.ErrNoTextLayer)
+}
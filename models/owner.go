@@ -0,0 +1,86 @@
+package models
+
+import "strings"
+
+// ownerSeparator separates the member codes of an item shared by a subset
+// of the members, e.g. "a+m".
+const ownerSeparator = "+"
+
+// ParseReceiptItemOwner parses a member code, the shared code or a
+// combination of member codes like "a+m". Combinations are normalized to
+// the order of members, a combination with a single member is that member,
+// and a combination with all the members is Shared.
+func ParseReceiptItemOwner(s string, members []ReceiptItemOwner) (ReceiptItemOwner, bool) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if ReceiptItemOwner(s) == Shared {
+		return Shared, true
+	}
+	chosen := make(map[ReceiptItemOwner]bool)
+	for _, tok := range strings.Split(s, ownerSeparator) {
+		owner := ReceiptItemOwner(strings.TrimSpace(tok))
+		if !owner.In(members) {
+			return "", false
+		}
+		chosen[owner] = true
+	}
+	var subset []string
+	for _, member := range members {
+		if chosen[member] {
+			subset = append(subset, string(member))
+		}
+	}
+	if len(subset) == len(members) && len(members) > 1 {
+		return Shared, true
+	}
+	return ReceiptItemOwner(strings.Join(subset, ownerSeparator)), true
+}
+
+// Members returns the members that own the item: all of them for Shared,
+// the chosen ones for a combination, or just the owner itself. Returns nil
+// if the owner is not valid for the given members.
+func (o ReceiptItemOwner) Members(members []ReceiptItemOwner) []ReceiptItemOwner {
+	if o == Shared {
+		return members
+	}
+	var subset []ReceiptItemOwner
+	for _, tok := range strings.Split(string(o), ownerSeparator) {
+		owner := ReceiptItemOwner(tok)
+		if !owner.In(members) || owner.In(subset) {
+			return nil
+		}
+		subset = append(subset, owner)
+	}
+	return subset
+}
+
+// IsValid tells whether the owner is a member, Shared or a combination of
+// members.
+func (o ReceiptItemOwner) IsValid(members []ReceiptItemOwner) bool {
+	return len(o.Members(members)) > 0
+}
+
+// IsShared tells whether the item is owned by more than one member.
+func (o ReceiptItemOwner) IsShared(members []ReceiptItemOwner) bool {
+	return len(o.Members(members)) > 1
+}
+
+// Subset tells whether all the members owning the item are in subset.
+func (o ReceiptItemOwner) Subset(members, subset []ReceiptItemOwner) bool {
+	owners := o.Members(members)
+	for _, owner := range owners {
+		if !owner.In(subset) {
+			return false
+		}
+	}
+	return len(owners) > 0
+}
+
+// In tells whether the owner is one of the given owners.
+func (o ReceiptItemOwner) In(owners []ReceiptItemOwner) bool {
+	for _, owner := range owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	regexQuantity      = regexp.MustCompile(`(?i)\b([0-9]+)\s*[x×*@]\s*([0-9]*[.,]?[0-9]+-?)(\s|$)`)
+	regexQuantityToken = regexp.MustCompile(`^([0-9]+)x([0-9]*[.,]?[0-9]+-?)$`)
+	regexThousands     = regexp.MustCompile(`^[0-9]{1,3}([.,][0-9]{3})+$`)
+	regexIntegerPart   = regexp.MustCompile(`^([0-9]{1,3}([.,][0-9]{3})*|[0-9]*)$`)
+	regexVATMarker     = regexp.MustCompile(`^([A-Z]\*?|\*|[0-9]{1,2}%)$`)
+	regexTotalLine     = regexp.MustCompile(`(?i)^(grand )?(total|totaal|summe|gesamt|balance|amount)( to pay| due| a pagar)?:?$|^(te betalen|to pay):?$`)
+	regexSummaryLine   = regexp.MustCompile(`(?i)^(sub-? ?total|vat|tax|btw|mwst|change|you saved|total savings|savings)\b`)
+	regexPaymentLine   = regexp.MustCompile(`(?i)^(cash|card|visa|mastercard|maestro|amex|contactless|debit card|credit card|pin)\b`)
+)
+
+// ParseReceipt parses a receipt pasted as text. Each line usually has one
+// item, with the name followed by the price and optionally by VAT markers
+// like "A" or "B". Prices may use comma decimals, thousands separators,
+// currency symbols and a trailing minus for discounts. A quantity like
+// "3 x 1.29" also ends an item, and an explicit line total right after it
+// is consumed if it matches the quantity times the unit price.
+//
+// A line may also have several items, as long as the prices in the middle
+// of the line have decimal places, so numbers in names like "Eggs 12 2.99"
+// are not mistaken for prices. A line without a price is the name of the
+// item in the next line if that line has only a price.
+//
+// Total lines set Total, payment lines set PaymentMethod, and other summary
+// lines like subtotals and VAT are skipped. The currency is detected from
+// symbols like "£" or codes like "CHF", and is empty if none is found. The
+// lines that could not be parsed are returned.
+func ParseReceipt(receiptText string) (receipt *Receipt, unparsed []string) {
+	receipt = &Receipt{}
+	currency, _ := DetectCurrency(receiptText)
+	receipt.Currency = currency
+	p := &receiptParser{currency: currency.OrDefault()}
+
+	var pendingName string
+	for _, line := range strings.Split(receiptText, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		items, ok := p.parseLine(line)
+		if !ok {
+			if pendingName != "" {
+				unparsed = append(unparsed, pendingName)
+			}
+			pendingName = line
+			continue
+		}
+		if items[0].Name == "" && pendingName != "" {
+			items[0].Name, pendingName = pendingName, ""
+		}
+		if pendingName != "" {
+			unparsed = append(unparsed, pendingName)
+			pendingName = ""
+		}
+		for _, item := range items {
+			switch {
+			case item.Name == "":
+				unparsed = append(unparsed, line)
+			case regexTotalLine.MatchString(item.Name):
+				if receipt.Total == nil {
+					total := item.Price
+					receipt.Total = &total
+				}
+			case regexPaymentLine.MatchString(item.Name):
+				if receipt.PaymentMethod == "" {
+					receipt.PaymentMethod = strings.ToLower(regexPaymentLine.FindString(item.Name))
+				}
+			case regexSummaryLine.MatchString(item.Name):
+			default:
+				receipt.Items = append(receipt.Items, item)
+			}
+		}
+	}
+	if pendingName != "" {
+		unparsed = append(unparsed, pendingName)
+	}
+	return receipt, unparsed
+}
+
+type receiptParser struct {
+	currency Currency
+}
+
+// parseLine parses the items of a line. It returns false if the line does
+// not end with a price.
+func (p *receiptParser) parseLine(line string) ([]*ReceiptItem, bool) {
+	line = RemoveCurrencySymbols(line)
+	line = regexQuantity.ReplaceAllString(line, "${1}x${2}${3}")
+	tokens := strings.Fields(line)
+
+	// drop the VAT markers after the last price
+	end := len(tokens)
+	for end > 0 && regexVATMarker.MatchString(tokens[end-1]) {
+		end--
+	}
+	if end == 0 || !p.isPriceBoundary(tokens[end-1], true) {
+		return nil, false
+	}
+	tokens = tokens[:end]
+
+	var items []*ReceiptItem
+	var nameTokens []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		last := i == len(tokens)-1
+		if !p.isPriceBoundary(tok, last) {
+			nameTokens = append(nameTokens, tok)
+			continue
+		}
+		item := &ReceiptItem{Name: strings.Join(nameTokens, " ")}
+		nameTokens = nil
+		if m := regexQuantityToken.FindStringSubmatch(tok); m != nil && p.isQuantityToken(tok) {
+			item.Quantity, _ = strconv.Atoi(m[1])
+			item.UnitPrice, _ = p.parsePrice(m[2])
+			item.Price = PriceInCents(item.Quantity) * item.UnitPrice
+			if i+1 < len(tokens) {
+				if total, ok := p.parsePrice(tokens[i+1]); ok && total == item.Price {
+					i++
+				}
+			}
+		} else {
+			item.Price, _ = p.parsePrice(tok)
+		}
+		// VAT markers may also follow prices in the middle of the line
+		for i+1 < len(tokens) && regexVATMarker.MatchString(tokens[i+1]) {
+			i++
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// isPriceBoundary tells whether tok ends an item. Prices in the middle of a
+// line must have decimal places, unless the currency has none.
+func (p *receiptParser) isPriceBoundary(tok string, last bool) bool {
+	if p.isQuantityToken(tok) {
+		return true
+	}
+	normalized, ok := normalizePrice(tok, p.currency.MinorUnits())
+	if !ok {
+		return false
+	}
+	if _, ok := p.currency.ParsePrice(normalized); !ok {
+		return false
+	}
+	return last || (p.currency.MinorUnits() > 0 && strings.Contains(normalized, "."))
+}
+
+// isQuantityToken tells whether tok is a quantity like "3x1.29". The unit
+// price must have decimal places if the currency has them.
+func (p *receiptParser) isQuantityToken(tok string) bool {
+	m := regexQuantityToken.FindStringSubmatch(tok)
+	if m == nil {
+		return false
+	}
+	normalized, ok := normalizePrice(m[2], p.currency.MinorUnits())
+	if !ok || (p.currency.MinorUnits() > 0 && !strings.Contains(normalized, ".")) {
+		return false
+	}
+	_, ok = p.currency.ParsePrice(normalized)
+	return ok
+}
+
+func (p *receiptParser) parsePrice(tok string) (PriceInCents, bool) {
+	normalized, ok := normalizePrice(tok, p.currency.MinorUnits())
+	if !ok {
+		return 0, false
+	}
+	return p.currency.ParsePrice(normalized)
+}
+
+// normalizePrice rewrites a price like "1.234,56", "1,99" or "1.99-" as
+// "1234.56", "1.99" or "-1.99". A separator followed by three digits is a
+// thousands separator, unless the currency has three decimal places.
+func normalizePrice(tok string, minorUnits int) (string, bool) {
+	sign := ""
+	switch {
+	case strings.HasSuffix(tok, "-"):
+		sign, tok = "-", strings.TrimSuffix(tok, "-")
+	case strings.HasPrefix(tok, "-"):
+		sign, tok = "-", strings.TrimPrefix(tok, "-")
+	}
+	if minorUnits != 3 && regexThousands.MatchString(tok) {
+		return sign + strings.NewReplacer(".", "", ",", "").Replace(tok), true
+	}
+	sep := strings.LastIndexAny(tok, ".,")
+	if sep < 0 {
+		return sign + tok, true
+	}
+	integer, fraction := tok[:sep], tok[sep+1:]
+	if !regexIntegerPart.MatchString(integer) {
+		return "", false
+	}
+	integer = strings.NewReplacer(".", "", ",", "").Replace(integer)
+	return sign + integer + "." + fraction, true
+}
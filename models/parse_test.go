@@ -0,0 +1,168 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReceipt(t *testing.T) {
+	total := func(p models.PriceInCents) *models.PriceInCents { return &p }
+	for _, tt := range []struct {
+		name     string
+		text     string
+		expected *models.Receipt
+		unparsed []string
+	}{
+		{
+			name: "single line",
+			text: "Coca Cola 2L 1.50 Bread .99",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Coca Cola 2L", Price: 150},
+				&models.ReceiptItem{Name: "Bread", Price: 99},
+			),
+		},
+		{
+			name: "quantity",
+			text: "Yogurt 3 x 1.29\nBread 0.99",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Yogurt", Price: 387, Quantity: 3, UnitPrice: 129},
+				&models.ReceiptItem{Name: "Bread", Price: 99},
+			),
+		},
+		{
+			name: "quantities with line totals",
+			text: "Yogurt 3x1.29 3.87 Bread 2 @ .50 1.00 Eggs 2.10",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Yogurt", Price: 387, Quantity: 3, UnitPrice: 129},
+				&models.ReceiptItem{Name: "Bread", Price: 100, Quantity: 2, UnitPrice: 50},
+				&models.ReceiptItem{Name: "Eggs", Price: 210},
+			),
+		},
+		{
+			name: "comma decimals",
+			text: "Melk 1,99\nKaas 4,50\nKorting 0,50-",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Melk", Price: 199},
+				&models.ReceiptItem{Name: "Kaas", Price: 450},
+				&models.ReceiptItem{Name: "Korting", Price: -50},
+			),
+		},
+		{
+			name: "currency symbols before and after prices",
+			text: "Milch €1.99\nBrot 2.49€\nKäse € 3,10",
+			expected: &models.Receipt{
+				Currency: "EUR",
+				Items: []*models.ReceiptItem{
+					{Name: "Milch", Price: 199},
+					{Name: "Brot", Price: 249},
+					{Name: "Käse", Price: 310},
+				},
+			},
+		},
+		{
+			name: "thousands separators",
+			text: "TV 1.299,00\nLaptop 1,049.99\nSofa 1,200",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "TV", Price: 129900},
+				&models.ReceiptItem{Name: "Laptop", Price: 104999},
+				&models.ReceiptItem{Name: "Sofa", Price: 120000},
+			),
+		},
+		{
+			name: "numbers in names",
+			text: "7UP 1.50\nEggs 12 2.99\nWater 6x500ml 3.20",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "7UP", Price: 150},
+				&models.ReceiptItem{Name: "Eggs 12", Price: 299},
+				&models.ReceiptItem{Name: "Water 6x500ml", Price: 320},
+			),
+		},
+		{
+			name: "VAT markers",
+			text: "Milk 1.20 A\nWine 7.99 B*\nBread 0.95 C Butter 1.85 A",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "Milk", Price: 120},
+				&models.ReceiptItem{Name: "Wine", Price: 799},
+				&models.ReceiptItem{Name: "Bread", Price: 95},
+				&models.ReceiptItem{Name: "Butter", Price: 185},
+			),
+		},
+		{
+			name: "wrapped name",
+			text: "ORGANIC WHOLE MILK\n2 x 1.10 2.20\nBread 0.95",
+			expected: newReceipt(
+				&models.ReceiptItem{Name: "ORGANIC WHOLE MILK", Price: 220, Quantity: 2, UnitPrice: 110},
+				&models.ReceiptItem{Name: "Bread", Price: 95},
+			),
+		},
+		{
+			name: "UK receipt with summary lines",
+			text: `TESCO EXPRESS
+LONDON SE1
+Milk £1.20 A
+Clubcard Price Saving 0.20-
+Bread £0.95
+SUBTOTAL £1.95
+TOTAL £1.95
+VISA £1.95
+CHANGE £0.00`,
+			expected: &models.Receipt{
+				Currency: "GBP",
+				Items: []*models.ReceiptItem{
+					{Name: "Milk", Price: 120},
+					{Name: "Clubcard Price Saving", Price: -20},
+					{Name: "Bread", Price: 95},
+				},
+				Total:         total(195),
+				PaymentMethod: "visa",
+			},
+			unparsed: []string{"TESCO EXPRESS", "LONDON SE1"},
+		},
+		{
+			name: "Dutch receipt",
+			text: `Appels 2,49
+Stroopwafels 1,89 B
+2 x 0,99 Spa Rood
+TE BETALEN 4,38
+PIN 4,38`,
+			expected: &models.Receipt{
+				Items: []*models.ReceiptItem{
+					{Name: "Appels", Price: 249},
+					{Name: "Stroopwafels", Price: 189},
+				},
+				Total:         total(438),
+				PaymentMethod: "pin",
+			},
+			unparsed: []string{"2 x 0,99 Spa Rood"},
+		},
+		{
+			name: "currency without minor units",
+			text: "Onigiri ¥150\nGreen Tea 2 x 120\nTotal 390",
+			expected: &models.Receipt{
+				Currency: "JPY",
+				Items: []*models.ReceiptItem{
+					{Name: "Onigiri", Price: 150},
+					{Name: "Green Tea", Price: 240, Quantity: 2, UnitPrice: 120},
+				},
+				Total: total(390),
+			},
+		},
+		{
+			name:     "no prices",
+			text:     "hello there",
+			expected: &models.Receipt{},
+			unparsed: []string{"hello there"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			actual, unparsed := models.ParseReceipt(tt.text)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.unparsed, unparsed)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/matheuscscp/splitwiser/pkg/pdf"
+)
+
+type (
+	// PDFImporter imports e-receipts from the text layer of PDF files, which
+	// is parsed like a receipt pasted as text, see ParseReceipt.
+	PDFImporter struct {
+		// TextExtractor is the pdftotext-compatible binary, see
+		// pdf.ExtractText.
+		TextExtractor string
+	}
+)
+
+var (
+	// ErrNoTextLayer is returned for PDF files without text, like scanned
+	// receipts, which must be read from images of their pages instead.
+	ErrNoTextLayer = errors.New("PDF file has no text layer")
+)
+
+// Accepts ...
+func (PDFImporter) Accepts(fileName, mimeType string) bool {
+	return hasExtension(fileName, ".pdf") || mimeType == "application/pdf"
+}
+
+// Import ...
+func (p PDFImporter) Import(b []byte) (*Receipt, error) {
+	text, err := pdf.ExtractText(context.Background(), b, p.TextExtractor)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting text from PDF: %w", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrNoTextLayer
+	}
+	receipt, _ := ParseReceipt(text)
+	return receipt, nil
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	regexQuantitySplitToken = regexp.MustCompile(`^([^=]+)=([0-9]+)$`)
+)
+
+// CompletePrices fills in the price of the items that only have a quantity
+// and a unit price.
+func (r *Receipt) CompletePrices() {
+	for _, item := range r.Items {
+		if item.Price == 0 && item.Quantity > 0 && item.UnitPrice != 0 {
+			item.Price = PriceInCents(item.Quantity) * item.UnitPrice
+		}
+	}
+}
+
+// ParseQuantitySplit parses a split of the units of an item between owners
+// like "a=2 s=1", meaning two units owned by a and one shared.
+func ParseQuantitySplit(s string, members []ReceiptItemOwner) ([]ReceiptItemOwner, []int, error) {
+	var owners []ReceiptItemOwner
+	var quantities []int
+	for _, tok := range regexSpaces.Split(strings.TrimSpace(strings.ToLower(s)), -1) {
+		if tok == "" {
+			continue
+		}
+		m := regexQuantitySplitToken.FindStringSubmatch(tok)
+		if m == nil {
+			return nil, nil, fmt.Errorf("'%s' is not in the format <owner>=<quantity>", tok)
+		}
+		owner, ok := ParseReceiptItemOwner(m[1], members)
+		if !ok {
+			return nil, nil, fmt.Errorf("'%s' is not a valid owner", m[1])
+		}
+		if owner.In(owners) {
+			return nil, nil, fmt.Errorf("'%s' appears more than once", owner)
+		}
+		quantity, _ := strconv.Atoi(m[2])
+		if quantity <= 0 {
+			return nil, nil, fmt.Errorf("quantity of '%s' must be positive", owner)
+		}
+		owners = append(owners, owner)
+		quantities = append(quantities, quantity)
+	}
+	if len(owners) < 2 {
+		return nil, nil, errors.New("at least two owners are needed to split an item")
+	}
+	return owners, quantities, nil
+}
+
+// SplitItem splits the item at index i into one item per owner with the
+// given quantities, which must add up to the quantity of the item. The price
+// is split in proportion to the quantities, and so are the discounts linked
+// to the item.
+func (r *Receipt) SplitItem(i int, owners []ReceiptItemOwner, quantities []int) error {
+	if i < 0 || i >= r.Len() {
+		return errors.New("item number out of range")
+	}
+	if len(owners) != len(quantities) || len(owners) < 2 {
+		return errors.New("at least two owners are needed to split an item")
+	}
+	item := r.Items[i]
+	var total int
+	weights := make([]int64, len(quantities))
+	for k, q := range quantities {
+		total += q
+		weights[k] = int64(q)
+	}
+	if item.Quantity < 2 {
+		return fmt.Errorf("'%s' has a single unit", item.Name)
+	}
+	if total != item.Quantity {
+		return fmt.Errorf("quantities add up to %d, but '%s' has %d units", total, item.Name, item.Quantity)
+	}
+
+	// compute where each old item goes, the split item and its discounts
+	// take one position per part
+	start := make([]int, r.Len())
+	next := 0
+	for j := range r.Items {
+		start[j] = next
+		if j == i || (r.Items[j].DiscountOf != nil && *r.Items[j].DiscountOf == i) {
+			next += len(quantities)
+		} else {
+			next++
+		}
+	}
+
+	split := make([]*ReceiptItem, 0, next)
+	for j, old := range r.Items {
+		switch {
+		case j == i:
+			for k, price := range allocate(item.Price, weights) {
+				split = append(split, &ReceiptItem{
+					Name:      item.Name,
+					Price:     price,
+					Quantity:  quantities[k],
+					UnitPrice: item.UnitPrice,
+				})
+			}
+		case old.DiscountOf != nil && *old.DiscountOf == i:
+			for k, price := range allocate(old.Price, weights) {
+				discountOf := start[i] + k
+				split = append(split, &ReceiptItem{
+					Name:       old.Name,
+					Price:      price,
+					DiscountOf: &discountOf,
+				})
+			}
+		default:
+			if old.DiscountOf != nil {
+				discountOf := start[*old.DiscountOf]
+				old.DiscountOf = &discountOf
+			}
+			split = append(split, old)
+		}
+	}
+	r.Items = split
+	for k, owner := range owners {
+		r.SetItemOwner(start[i]+k, owner, nil)
+	}
+	return nil
+}
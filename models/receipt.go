@@ -1,74 +1,114 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 )
 
 type (
-	Receipt []*ReceiptItem
+	// Receipt is a receipt being split. The schema tags describe the fields
+	// extracted by models, see ReceiptSchema.
+	Receipt struct {
+		Items    []*ReceiptItem `json:"items" schema:"the items, fees and discounts of the receipt, in order"`
+		Currency Currency       `json:"currency" schema:"ISO 4217 code of the currency of the receipt, like EUR"`
+
+		// Store, Date, Total and PaymentMethod are the metadata printed on
+		// the receipt, when known. Date is "2006-01-02" optionally followed
+		// by the time, like "2006-01-02 15:04", and Total is the printed
+		// grand total.
+		Store         string        `json:"store,omitempty" schema:"name of the store"`
+		Date          string        `json:"date,omitempty" schema:"purchase date as YYYY-MM-DD, followed by the time as HH:MM if printed"`
+		Total         *PriceInCents `json:"total,omitempty" schema:"printed grand total in the minor unit of the currency"`
+		PaymentMethod string        `json:"payment_method,omitempty" schema:"how the receipt was paid, like card, cash or voucher"`
+
+		// TotalOverridden tells that a difference between Total and the sum
+		// of the items was accepted, see Reconcile.
+		TotalOverridden bool `json:"total_overridden,omitempty"`
+
+		// PromptVersion is the version of the prompt of the model that
+		// extracted the receipt, if any.
+		PromptVersion string `json:"prompt_version,omitempty"`
+
+		// CacheKey is the key of the images the receipt was read from, if
+		// any, under which the final receipt is cached.
+		CacheKey string `json:"cache_key,omitempty"`
+
+		// History is the log of the changes made while splitting the
+		// receipt, stored with the checkpoint.
+		History *History `json:"history,omitempty"`
+	}
 
 	ReceiptItem struct {
-		Name  string           `json:"name"`
-		Price PriceInCents     `json:"euro_cents"`
-		Owner ReceiptItemOwner `json:"owner"`
+		Name string `json:"name" schema:"name of the item as printed"`
+
+		// Price and UnitPrice are in the minor unit of the receipt currency.
+		Price     PriceInCents       `json:"price" schema:"total price of the line in the minor unit of the currency, negative for discounts"`
+		Quantity  int                `json:"quantity,omitempty" schema:"number of units, for lines with several units of a product"`
+		UnitPrice PriceInCents       `json:"unit_price,omitempty" schema:"price of one unit in the minor unit of the currency"`
+		Owner     ReceiptItemOwner   `json:"owner"`
+		Weights   ReceiptItemWeights `json:"weights,omitempty"`
+
+		// DiscountOf is the index of the item discounted by this item.
+		DiscountOf *int `json:"discount_of,omitempty"`
+
+		// Confidence tells that Owner was suggested from previous receipts
+		// and not accepted yet, see SuggestItemOwner.
+		Confidence float64 `json:"confidence,omitempty"`
 	}
 
+	// PriceInCents is an amount in the minor unit of a currency, which is
+	// not always a cent, see Currency.
 	PriceInCents int
 
 	ReceiptItemOwner string
 )
 
 const (
-	Ana     ReceiptItemOwner = "a"
-	Matheus ReceiptItemOwner = "m"
-	Shared  ReceiptItemOwner = "s"
+	Shared ReceiptItemOwner = "s"
+
+	// WholeReceipt is the owner of the items spread over the whole receipt
+	// in proportion to the totals of the other owners, like a discount on
+	// the receipt total.
+	WholeReceipt ReceiptItemOwner = "t"
 
 	zeroCents PriceInCents = 0
 )
 
 var (
-	regexSpaces     = regexp.MustCompile(`\s+`)
-	regexPriceToken = regexp.MustCompile(`^\s*(-{0,1})([0-9]*)((\.([0-9]{1,2})){0,1})\s*$`)
+	receiptDateLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
 )
 
-func ParseReceipt(receiptText string) (receipt Receipt) {
-	var tokens []string
-	priceIdxs := []int{-1}
-	for _, tok := range regexSpaces.Split(receiptText, -1) {
-		if tok == "" {
-			continue
-		}
-		if regexPriceToken.MatchString(tok) {
-			priceIdxs = append(priceIdxs, len(tokens))
-		}
-		tokens = append(tokens, tok)
-	}
-	for i := 1; i < len(priceIdxs); i++ {
-		priceIdx := priceIdxs[i]
-		nameTokens := tokens[priceIdxs[i-1]+1 : priceIdx]
-		receipt = append(receipt, &ReceiptItem{
-			Name:  strings.Join(nameTokens, " "),
-			Price: MustParsePriceInCents(tokens[priceIdx]),
-		})
-	}
-	return
-}
+var (
+	regexSpaces = regexp.MustCompile(`\s+`)
+)
 
-func (r Receipt) AbsoluteTotal() (total PriceInCents) {
-	for _, item := range r {
+func (r *Receipt) AbsoluteTotal() (total PriceInCents) {
+	for _, item := range r.Items {
 		total += item.Price
 	}
 	return
 }
 
-func (r Receipt) ComputeTotals() (ownerTotals map[ReceiptItemOwner]PriceInCents,
+// ComputeTotals sums the prices of the items of each owner, i.e. each
+// member, each combination of members, Shared and WholeReceipt.
+func (r *Receipt) ComputeTotals(members []ReceiptItemOwner) (ownerTotals map[ReceiptItemOwner]PriceInCents,
 	total PriceInCents, totalWithDiscounts PriceInCents) {
 	ownerTotals = make(map[ReceiptItemOwner]PriceInCents)
-	for _, item := range r {
-		if item.Owner == Ana || item.Owner == Matheus || item.Owner == Shared {
+	for _, item := range r.Items {
+		if item.Owner.IsValid(members) || item.Owner == WholeReceipt {
 			ownerTotals[item.Owner] += item.Price
 			totalWithDiscounts += item.Price
 			if item.Price > 0 {
@@ -79,147 +119,257 @@ func (r Receipt) ComputeTotals() (ownerTotals map[ReceiptItemOwner]PriceInCents,
 	return
 }
 
-func (r Receipt) ComputeExpenses(payer ReceiptItemOwner) (
+// ComputeExpenses splits the receipt into two expenses paid by payer: one
+// for the items owned by the other members individually, and one for the
+// items shared by more than one member, where each item is split across
+// the members sharing it according to its weights, or evenly if it has none.
+// Discounts that cannot stand on their own are allocated proportionally, see
+// allocateDiscounts. An error is returned if they exceed the items they can
+// be allocated to.
+func (r *Receipt) ComputeExpenses(members []ReceiptItemOwner, payer ReceiptItemOwner, loc *time.Location) (
 	nonSharedExpense *Expense,
 	sharedExpense *Expense,
+	err error,
 ) {
-	ownerTotals, _, _ := r.ComputeTotals()
-	if sharedTotal := ownerTotals[Shared]; sharedTotal < 0 {
-		min, max := Ana, Matheus
-		if ownerTotals[Matheus] < ownerTotals[Ana] {
-			min, max = Matheus, Ana
-		}
-		if halfSharedTotal := sharedTotal / 2; ownerTotals[min]+halfSharedTotal >= 0 {
-			ownerTotals[min] += halfSharedTotal
-			ownerTotals[max] += sharedTotal - halfSharedTotal
-		} else {
-			minTotal := ownerTotals[min]
-			ownerTotals[min] = 0
-			ownerTotals[max] += sharedTotal + minTotal
-		}
-		ownerTotals[Shared] = 0
+	ownerTotals, _, _ := r.ComputeTotals(members)
+	finalTotals, leftovers := allocateDiscounts(ownerTotals, members)
+	var errs []error
+	for _, owner := range sortedOwners(leftovers) {
+		errs = append(errs, fmt.Errorf("%s of the items of '%s' cannot be allocated to other items",
+			r.Format(leftovers[owner]), owner))
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
 	}
 
-	cost, borrower, description := ownerTotals[Ana], Ana, "vegan"
-	if payer == Ana {
-		cost, borrower, description = ownerTotals[Matheus], Matheus, "non-vegan"
+	// the payer goes last so the other members absorb the remainder cents
+	borrowers := make([]ReceiptItemOwner, 0, len(members))
+	for _, member := range members {
+		if member != payer {
+			borrowers = append(borrowers, member)
+		}
 	}
+	order := append(borrowers, payer)
+
+	payerShare := &UserShare{User: payer}
+	currency := r.Currency.OrDefault()
+	date, _ := r.PurchaseDate(loc)
 	nonSharedExpense = &Expense{
-		Cost: cost,
-		UserShares: [2]*UserShare{
-			{
-				User: payer,
-				Paid: cost,
-				Owed: zeroCents,
-			},
-			{
-				User: borrower,
-				Paid: zeroCents,
-				Owed: cost,
-			},
-		},
-		Description: description,
-	}
-
-	costShared := ownerTotals[Shared]
-	halfCostSharedRoundedDown := costShared / 2
-	halfCostSharedRoundedUp := (costShared + 1) / 2
+		Currency:    currency,
+		Date:        date,
+		UserShares:  []*UserShare{payerShare},
+		Description: "non-shared",
+	}
+	for _, borrower := range borrowers {
+		cost := finalTotals[borrower]
+		nonSharedExpense.Cost += cost
+		nonSharedExpense.UserShares = append(nonSharedExpense.UserShares, &UserShare{
+			User: borrower,
+			Paid: zeroCents,
+			Owed: cost,
+		})
+	}
+	payerShare.Paid = nonSharedExpense.Cost
+
+	// each item is split according to its weights, and then the split is
+	// scaled by the ratio between the final and the original total of its
+	// owner
+	var costShared PriceInCents
+	exactShares := make([]*big.Rat, len(order))
+	for i := range exactShares {
+		exactShares[i] = new(big.Rat)
+	}
+	for _, owner := range sortedOwners(finalTotals) {
+		if owner.IsShared(members) {
+			costShared += finalTotals[owner]
+		}
+	}
+	for _, item := range r.Items {
+		if !item.Owner.IsShared(members) || ownerTotals[item.Owner] == 0 {
+			continue
+		}
+		subset := item.Owner.Members(members)
+		weights := item.Weights
+		var totalWeight int64
+		for _, member := range subset {
+			totalWeight += weights.Of(member)
+		}
+		if totalWeight <= 0 {
+			weights, totalWeight = nil, int64(len(subset))
+		}
+		scale := big.NewRat(int64(finalTotals[item.Owner]), int64(ownerTotals[item.Owner]))
+		for i, member := range order {
+			if member.In(subset) {
+				share := big.NewRat(int64(item.Price)*weights.Of(member), totalWeight)
+				exactShares[i].Add(exactShares[i], share.Mul(share, scale))
+			}
+		}
+	}
+	owed := roundShares(exactShares)
 	sharedExpense = &Expense{
-		Cost: costShared,
-		UserShares: [2]*UserShare{
-			{
-				User: payer,
-				Paid: costShared,
-				Owed: halfCostSharedRoundedDown,
-			},
-			{
-				User: borrower,
-				Paid: zeroCents,
-				Owed: halfCostSharedRoundedUp,
-			},
-		},
+		Cost:     costShared,
+		Currency: currency,
+		Date:     date,
+		UserShares: []*UserShare{{
+			User: payer,
+			Paid: costShared,
+			Owed: owed[len(order)-1],
+		}},
 		Description: "shared",
 	}
+	for i, borrower := range borrowers {
+		sharedExpense.UserShares = append(sharedExpense.UserShares, &UserShare{
+			User: borrower,
+			Paid: zeroCents,
+			Owed: owed[i],
+		})
+	}
 
 	return
 }
 
-func (r Receipt) Len() int {
-	return len(r)
+// sortedOwners returns the owners of the given totals in a deterministic order.
+func sortedOwners(ownerTotals map[ReceiptItemOwner]PriceInCents) []ReceiptItemOwner {
+	owners := make([]ReceiptItemOwner, 0, len(ownerTotals))
+	for owner := range ownerTotals {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+	return owners
 }
 
-func (r Receipt) NextItem(curItem int) int {
+// Owners returns the distinct owners of the items in order of appearance.
+func (r *Receipt) Owners() []ReceiptItemOwner {
+	var owners []ReceiptItemOwner
+	for _, item := range r.Items {
+		if item.Owner != "" && !item.Owner.In(owners) {
+			owners = append(owners, item.Owner)
+		}
+	}
+	return owners
+}
+
+func (r *Receipt) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Items)
+}
+
+func (r *Receipt) NextItem(curItem int) int {
 	return (curItem + 1) % r.Len()
 }
 
-func (r Receipt) String() string {
-	items := make([]string, r.Len())
-	for i := range r {
-		items[i] = r[i].String()
+// NextPendingItem returns the first item pending a decision starting from
+// curItem and wrapping around, or curItem if there is none.
+func (r *Receipt) NextPendingItem(curItem int) int {
+	for i := 0; i < r.Len(); i++ {
+		if item := (curItem + i) % r.Len(); r.IsPending(item) {
+			return item
+		}
 	}
-	items = append(items, "")
-	items = append(items, fmt.Sprintf("Total: %s", r.AbsoluteTotal()))
-	return strings.Join(items, "\n")
+	return curItem
 }
 
-func (r *ReceiptItem) String() string {
-	return fmt.Sprintf("%s (%s)", r.Name, r.Price)
+// PurchaseDate parses the printed date of the receipt in the time zone
+// where it was printed, unless the date has an offset. A date without time
+// is set to noon so it falls on the same day in any time zone.
+func (r *Receipt) PurchaseDate(loc *time.Location) (time.Time, bool) {
+	date := strings.TrimSpace(r.Date)
+	for _, layout := range receiptDateLayouts {
+		t, err := time.ParseInLocation(layout, date, loc)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.Add(12 * time.Hour)
+		}
+		return t, true
+	}
+	return time.Time{}, false
 }
 
-func ParsePriceInCents(tok string) (PriceInCents, bool) {
-	m := regexPriceToken.FindStringSubmatch(tok)
-	if m == nil {
-		return 0, false
+func (r *Receipt) String() string {
+	var header []string
+	if r.Store != "" {
+		header = append(header, fmt.Sprintf("Store: %s", r.Store))
+	}
+	if r.Date != "" {
+		header = append(header, fmt.Sprintf("Date: %s", r.Date))
 	}
-	sign, eurosStr, centsStr := m[1], m[2], m[5]
-	euros, _ := strconv.ParseInt(eurosStr, 10, 64)
-	if len(eurosStr) == 0 {
-		euros = 0
+	if r.PaymentMethod != "" {
+		header = append(header, fmt.Sprintf("Payment method: %s", r.PaymentMethod))
 	}
-	cents, _ := strconv.ParseInt(centsStr, 10, 64)
-	if len(centsStr) == 1 {
-		cents *= 10
+	if len(header) > 0 {
+		header = append(header, "")
 	}
-	price := PriceInCents(cents + 100*euros)
-	if sign == "-" {
-		price = -price
+	items := make([]string, r.Len())
+	for i, item := range r.Items {
+		items[i] = fmt.Sprintf("%d. %s", i+1, item.Format(r.Currency))
+		if item.DiscountOf != nil {
+			items[i] += fmt.Sprintf(" [discount of %d]", *item.DiscountOf+1)
+		}
+	}
+	items = append(items, "")
+	items = append(items, fmt.Sprintf("Total: %s", r.Format(r.AbsoluteTotal())))
+	if r.Total != nil {
+		items = append(items, fmt.Sprintf("Printed total: %s", r.Format(*r.Total)))
 	}
-	return price, true
+	return strings.Join(append(header, items...), "\n")
 }
 
-func MustParsePriceInCents(tok string) PriceInCents {
-	price, ok := ParsePriceInCents(tok)
-	if !ok {
-		panic(fmt.Errorf("%q does not match pattern %q", tok, regexPriceToken.String()))
-	}
-	return price
+// Format formats a price in the currency of the receipt, with its code.
+func (r *Receipt) Format(p PriceInCents) string {
+	currency := r.Currency.OrDefault()
+	return fmt.Sprintf("%s %s", currency.Format(p), currency)
 }
 
-func (p PriceInCents) String() string {
-	i := int(p)
-	var s string
-	if i < 0 {
-		i = -i
-		s += "-"
+// Format formats the item with prices in the given currency.
+func (r *ReceiptItem) Format(currency Currency) string {
+	currency = currency.OrDefault()
+	if r.Quantity > 1 {
+		return fmt.Sprintf("%s (%d x %s = %s)", r.Name, r.Quantity, currency.Format(r.UnitPrice), currency.Format(r.Price))
 	}
-	s += fmt.Sprintf("%d.", i/100)
-	mod := i % 100
-	if mod < 10 {
-		s += "0"
+	return fmt.Sprintf("%s (%s)", r.Name, currency.Format(r.Price))
+}
+
+// SetOwner sets the owner of the item and discards its weights and any
+// suggestion.
+func (r *ReceiptItem) SetOwner(owner ReceiptItemOwner) {
+	r.Owner = owner
+	r.Weights = nil
+	r.Confidence = 0
+}
+
+// UnmarshalJSON also accepts the previous format of receipts, a plain array
+// of items in euros.
+func (r *Receipt) UnmarshalJSON(b []byte) error {
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		r.Currency = DefaultCurrency
+		return json.Unmarshal(b, &r.Items)
 	}
-	s += fmt.Sprintf("%d", mod)
-	return s
+	type receipt Receipt
+	return json.Unmarshal(b, (*receipt)(r))
 }
 
-func (o ReceiptItemOwner) Pretty() string {
-	switch o {
-	case Ana:
-		return "Ana"
-	case Matheus:
-		return "Matheus"
-	case Shared:
-		return "Shared"
-	default:
-		return string(o)
+// UnmarshalJSON also accepts the previous names of the price fields,
+// "euro_cents" and "unit_euro_cents".
+func (r *ReceiptItem) UnmarshalJSON(b []byte) error {
+	type receiptItem ReceiptItem
+	var item struct {
+		receiptItem
+		EuroCents     *PriceInCents `json:"euro_cents"`
+		UnitEuroCents *PriceInCents `json:"unit_euro_cents"`
+	}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return err
+	}
+	*r = ReceiptItem(item.receiptItem)
+	if item.EuroCents != nil {
+		r.Price = *item.EuroCents
+	}
+	if item.UnitEuroCents != nil {
+		r.UnitPrice = *item.UnitEuroCents
 	}
+	return nil
 }
@@ -2,31 +2,350 @@ package models_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/matheuscscp/splitwiser/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestParsePriceInCents(t *testing.T) {
+func TestComputeExpenses(t *testing.T) {
+	const a, m, j models.ReceiptItemOwner = "a", "m", "j"
+	for _, tt := range []struct {
+		name              string
+		members           []models.ReceiptItemOwner
+		payer             models.ReceiptItemOwner
+		receipt           *models.Receipt
+		expectedNonShared []*models.UserShare
+		expectedShared    []*models.UserShare
+		expectedErr       string
+	}{
+		{
+			name:    "two members",
+			members: []models.ReceiptItemOwner{a, m},
+			payer:   m,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "oat milk", Price: 150, Owner: a},
+				&models.ReceiptItem{Name: "whey", Price: 2000, Owner: m},
+				&models.ReceiptItem{Name: "bread", Price: 301, Owner: models.Shared},
+				&models.ReceiptItem{Name: "bag", Price: 10, Owner: "n"},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: m, Paid: 150, Owed: 0},
+				{User: a, Paid: 0, Owed: 150},
+			},
+			expectedShared: []*models.UserShare{
+				{User: m, Paid: 301, Owed: 150},
+				{User: a, Paid: 0, Owed: 151},
+			},
+		},
+		{
+			name:    "three members",
+			members: []models.ReceiptItemOwner{a, m, j},
+			payer:   a,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "tofu", Price: 200, Owner: a},
+				&models.ReceiptItem{Name: "beer", Price: 300, Owner: j},
+				&models.ReceiptItem{Name: "whey", Price: 2000, Owner: m},
+				&models.ReceiptItem{Name: "rice", Price: 100, Owner: models.Shared},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: a, Paid: 2300, Owed: 0},
+				{User: m, Paid: 0, Owed: 2000},
+				{User: j, Paid: 0, Owed: 300},
+			},
+			expectedShared: []*models.UserShare{
+				{User: a, Paid: 100, Owed: 33},
+				{User: m, Paid: 0, Owed: 34},
+				{User: j, Paid: 0, Owed: 33},
+			},
+		},
+		{
+			name:    "negative shared total",
+			members: []models.ReceiptItemOwner{a, m, j},
+			payer:   a,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "tofu", Price: 100, Owner: a},
+				&models.ReceiptItem{Name: "beer", Price: 50, Owner: j},
+				&models.ReceiptItem{Name: "whey", Price: 2000, Owner: m},
+				&models.ReceiptItem{Name: "voucher", Price: -300, Owner: models.Shared},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: a, Paid: 1764, Owed: 0},
+				{User: m, Paid: 0, Owed: 1721},
+				{User: j, Paid: 0, Owed: 43},
+			},
+			expectedShared: []*models.UserShare{
+				{User: a, Paid: 0, Owed: 0},
+				{User: m, Paid: 0, Owed: 0},
+				{User: j, Paid: 0, Owed: 0},
+			},
+		},
+		{
+			name:    "subset sharing",
+			members: []models.ReceiptItemOwner{a, m, j},
+			payer:   m,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "wine", Price: 1001, Owner: "a+j"},
+				&models.ReceiptItem{Name: "cheese", Price: 500, Owner: "m+j"},
+				&models.ReceiptItem{Name: "rice", Price: 300, Owner: models.Shared},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: m, Paid: 0, Owed: 0},
+				{User: a, Paid: 0, Owed: 0},
+				{User: j, Paid: 0, Owed: 0},
+			},
+			expectedShared: []*models.UserShare{
+				{User: m, Paid: 1801, Owed: 350},
+				{User: a, Paid: 0, Owed: 601},
+				{User: j, Paid: 0, Owed: 850},
+			},
+		},
+		{
+			name:    "weighted sharing",
+			members: []models.ReceiptItemOwner{a, m, j},
+			payer:   a,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "pizza", Price: 1000, Owner: "a+m", Weights: models.ReceiptItemWeights{a: 2, m: 1}},
+				&models.ReceiptItem{Name: "wine", Price: 999, Owner: "m+j", Weights: models.ReceiptItemWeights{m: 7000, j: 3000}},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: a, Paid: 0, Owed: 0},
+				{User: m, Paid: 0, Owed: 0},
+				{User: j, Paid: 0, Owed: 0},
+			},
+			expectedShared: []*models.UserShare{
+				{User: a, Paid: 1999, Owed: 667},
+				{User: m, Paid: 0, Owed: 1032},
+				{User: j, Paid: 0, Owed: 300},
+			},
+		},
+		{
+			name:    "negative subset total",
+			members: []models.ReceiptItemOwner{a, m, j},
+			payer:   j,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "tofu", Price: 300, Owner: a},
+				&models.ReceiptItem{Name: "whey", Price: 100, Owner: m},
+				&models.ReceiptItem{Name: "beer", Price: 500, Owner: j},
+				&models.ReceiptItem{Name: "rice", Price: 200, Owner: models.Shared},
+				&models.ReceiptItem{Name: "coupon", Price: -60, Owner: "a+m"},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: j, Paid: 340, Owed: 0},
+				{User: a, Paid: 0, Owed: 255},
+				{User: m, Paid: 0, Owed: 85},
+			},
+			expectedShared: []*models.UserShare{
+				{User: j, Paid: 200, Owed: 66},
+				{User: a, Paid: 0, Owed: 67},
+				{User: m, Paid: 0, Owed: 67},
+			},
+		},
+		{
+			name:    "linked and whole receipt discounts",
+			members: []models.ReceiptItemOwner{a, m},
+			payer:   m,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "wings", Price: 399, Owner: a},
+				&models.ReceiptItem{Name: "wings discount", Price: -100, Owner: a, DiscountOf: intPtr(0)},
+				&models.ReceiptItem{Name: "bread", Price: 301, Owner: models.Shared},
+				&models.ReceiptItem{Name: "whey", Price: 1000, Owner: m},
+				&models.ReceiptItem{Name: "10% off total", Price: -160, Owner: models.WholeReceipt},
+			),
+			expectedNonShared: []*models.UserShare{
+				{User: m, Paid: 269, Owed: 0},
+				{User: a, Paid: 0, Owed: 269},
+			},
+			expectedShared: []*models.UserShare{
+				{User: m, Paid: 271, Owed: 135},
+				{User: a, Paid: 0, Owed: 136},
+			},
+		},
+		{
+			name:    "discount exceeds subset",
+			members: []models.ReceiptItemOwner{a, m},
+			payer:   m,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "tofu", Price: 100, Owner: a},
+				&models.ReceiptItem{Name: "coupon", Price: -300, Owner: "a+m"},
+			),
+			expectedErr: "-2.00 EUR of the items of 'a+m' cannot be allocated to other items",
+		},
+		{
+			name:    "whole-receipt discount exceeds total",
+			members: []models.ReceiptItemOwner{a, m},
+			payer:   m,
+			receipt: newReceipt(
+				&models.ReceiptItem{Name: "tofu", Price: 100, Owner: a},
+				&models.ReceiptItem{Name: "voucher", Price: -200, Owner: models.WholeReceipt},
+			),
+			expectedErr: "-1.00 EUR of the items of 't' cannot be allocated to other items",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			nonShared, shared, err := tt.receipt.ComputeExpenses(tt.members, tt.payer, time.UTC)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedNonShared, nonShared.UserShares)
+			assert.Equal(t, tt.expectedShared, shared.UserShares)
+		})
+	}
+}
+
+func TestParseReceiptItemOwner(t *testing.T) {
+	members := []models.ReceiptItemOwner{"a", "m", "j"}
 	for _, tt := range []struct {
 		text     string
-		expected models.PriceInCents
+		expected models.ReceiptItemOwner
+		ok       bool
+	}{
+		{text: "a", expected: "a", ok: true},
+		{text: "s", expected: models.Shared, ok: true},
+		{text: "m+a", expected: "a+m", ok: true},
+		{text: " j + a ", expected: "a+j", ok: true},
+		{text: "a+a", expected: "a", ok: true},
+		{text: "a+m+j", expected: models.Shared, ok: true},
+		{text: "a+x", ok: false},
+		{text: "", ok: false},
+	} {
+		t.Run(tt.text, func(t *testing.T) {
+			actual, ok := models.ParseReceiptItemOwner(tt.text, members)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestParseReceiptItemWeights(t *testing.T) {
+	members := []models.ReceiptItemOwner{"a", "m", "j"}
+	for _, tt := range []struct {
+		text            string
+		expectedOwner   models.ReceiptItemOwner
+		expectedWeights models.ReceiptItemWeights
+		expectedErr     string
 	}{
-		{text: "-4", expected: -400},
-		{text: "4", expected: 400},
-		{text: ".4", expected: 40},
-		{text: ".40", expected: 40},
-		{text: "1.4", expected: 140},
-		{text: "1.40", expected: 140},
-		{text: "-.1", expected: -10},
-		{text: "-0.1", expected: -10},
+		{
+			text:            "a=2 m=1",
+			expectedOwner:   "a+m",
+			expectedWeights: models.ReceiptItemWeights{"a": 2, "m": 1},
+		},
+		{
+			text:            "j=70% a=30%",
+			expectedOwner:   "a+j",
+			expectedWeights: models.ReceiptItemWeights{"j": 7000, "a": 3000},
+		},
+		{
+			text:            "a=33.34% m=33.33% j=33.33%",
+			expectedOwner:   models.Shared,
+			expectedWeights: models.ReceiptItemWeights{"a": 3334, "m": 3333, "j": 3333},
+		},
+		{text: "a=2", expectedErr: "at least two members must be weighted"},
+		{text: "a=60% m=30%", expectedErr: "percentages add up to 90%, not 100%"},
+		{text: "a=60% m=1", expectedErr: "percentages and plain weights cannot be mixed"},
+		{text: "a=0 m=1", expectedErr: "weight of 'a' must be positive"},
+		{text: "a=1.5 m=1", expectedErr: "weight 'a=1.5' must be a whole number"},
+		{text: "x=1 m=1", expectedErr: "'x' is not a member"},
+		{text: "a=1 a=2", expectedErr: "'a' appears more than once"},
+		{text: "a:1 m=1", expectedErr: "'a:1' is not in the format <code>=<weight> or <code>=<percentage>%"},
 	} {
 		t.Run(tt.text, func(t *testing.T) {
+			owner, weights, err := models.ParseReceiptItemWeights(tt.text, members)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOwner, owner)
+			assert.Equal(t, tt.expectedWeights, weights)
+		})
+	}
+}
+
+func TestLinkDiscounts(t *testing.T) {
+	receipt := newReceipt(
+		&models.ReceiptItem{Name: "Smoky BBQ wings", Price: 399},
+		&models.ReceiptItem{Name: "Smoky BBQ wings Discount", Price: -100},
+		&models.ReceiptItem{Name: "Whole Milk 2L", Price: 209},
+		&models.ReceiptItem{Name: "Coupon", Price: -50},
+		&models.ReceiptItem{Name: "Whole Milk", Price: 109},
+		&models.ReceiptItem{Name: "Whole Milk 2L Discount", Price: -9},
+	)
+	assert.Equal(t, []int{1, 5}, receipt.LinkDiscounts())
+	assert.Equal(t, intPtr(0), receipt.Items[1].DiscountOf)
+	assert.Nil(t, receipt.Items[3].DiscountOf)
+	assert.Equal(t, intPtr(2), receipt.Items[5].DiscountOf)
+
+	receipt.SetItemOwner(0, "a", nil)
+	assert.Equal(t, models.ReceiptItemOwner("a"), receipt.Items[1].Owner)
+	assert.False(t, receipt.IsPending(1))
+	assert.True(t, receipt.IsPending(3))
+
+	assert.EqualError(t, receipt.LinkDiscount(3, 1), "'Smoky BBQ wings Discount' cannot be discounted")
+	assert.NoError(t, receipt.LinkDiscount(3, 4))
+	assert.NoError(t, receipt.UnlinkDiscount(1))
+	assert.True(t, receipt.IsPending(1))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestSplitItem(t *testing.T) {
+	receipt := newReceipt(
+		&models.ReceiptItem{Name: "Yogurt", Price: 388, Quantity: 3, UnitPrice: 129},
+		&models.ReceiptItem{Name: "Yogurt Discount", Price: -100, DiscountOf: intPtr(0)},
+		&models.ReceiptItem{Name: "Bread", Price: 99},
+		&models.ReceiptItem{Name: "Bread Discount", Price: -9, DiscountOf: intPtr(2)},
+	)
+	owners, quantities, err := models.ParseQuantitySplit("a=2 s=1", []models.ReceiptItemOwner{"a", "m"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, receipt.SplitItem(0, owners, quantities))
+	assert.Equal(t, newReceipt(
+		&models.ReceiptItem{Name: "Yogurt", Price: 259, Quantity: 2, UnitPrice: 129, Owner: "a"},
+		&models.ReceiptItem{Name: "Yogurt", Price: 129, Quantity: 1, UnitPrice: 129, Owner: models.Shared},
+		&models.ReceiptItem{Name: "Yogurt Discount", Price: -67, Owner: "a", DiscountOf: intPtr(0)},
+		&models.ReceiptItem{Name: "Yogurt Discount", Price: -33, Owner: models.Shared, DiscountOf: intPtr(1)},
+		&models.ReceiptItem{Name: "Bread", Price: 99},
+		&models.ReceiptItem{Name: "Bread Discount", Price: -9, DiscountOf: intPtr(4)},
+	), receipt)
+
+	assert.EqualError(t, receipt.SplitItem(4, owners, quantities), "'Bread' has a single unit")
+}
+
+func newReceipt(items ...*models.ReceiptItem) *models.Receipt {
+	return &models.Receipt{Items: items}
+}
+
+func TestPurchaseDate(t *testing.T) {
+	berlin := time.FixedZone("CET", 60*60)
+	for _, tt := range []struct {
+		date     string
+		expected time.Time
+		ok       bool
+	}{
+		{date: "2024-03-09", expected: time.Date(2024, 3, 9, 12, 0, 0, 0, berlin), ok: true},
+		{date: "2024-03-09 23:42", expected: time.Date(2024, 3, 9, 23, 42, 0, 0, berlin), ok: true},
+		{date: "2024-03-09T18:42:10", expected: time.Date(2024, 3, 9, 18, 42, 10, 0, berlin), ok: true},
+		{date: "2024-03-09T18:42:10Z", expected: time.Date(2024, 3, 9, 18, 42, 10, 0, time.UTC), ok: true},
+		{date: "09/03/2024", ok: false},
+		{date: "", ok: false},
+	} {
+		t.Run(tt.date, func(t *testing.T) {
 			tt := tt
 			t.Parallel()
 
-			actual := models.MustParsePriceInCents(tt.text)
-			assert.Equal(t, tt.expected, actual)
+			receipt := &models.Receipt{Date: tt.date}
+			actual, ok := receipt.PurchaseDate(berlin)
+			assert.Equal(t, tt.ok, ok)
+			assert.True(t, tt.expected.Equal(actual))
+			if ok {
+				assert.Equal(t, tt.date[:10], actual.In(berlin).Format("2006-01-02"))
+			}
 		})
 	}
 }
@@ -0,0 +1,71 @@
+package models
+
+import "strings"
+
+type (
+	// Reconciliation compares the printed total of a receipt with the sum
+	// of its items and lists the items that probably explain the difference.
+	Reconciliation struct {
+		Printed  PriceInCents
+		Computed PriceInCents
+
+		// Duplicates are groups of item indexes with the same name.
+		Duplicates [][]int
+		// UnpairedDiscounts are the indexes of the negative items that are
+		// not linked to an item.
+		UnpairedDiscounts []int
+		// Matches are the indexes of the items whose price is exactly the
+		// difference, either missed or counted twice.
+		Matches []int
+	}
+)
+
+// Reconcile compares the printed total with AbsoluteTotal. It returns false
+// if the printed total is unknown.
+func (r *Receipt) Reconcile() (*Reconciliation, bool) {
+	if r == nil || r.Total == nil {
+		return nil, false
+	}
+	rec := &Reconciliation{
+		Printed:  *r.Total,
+		Computed: r.AbsoluteTotal(),
+	}
+	if rec.Difference() == 0 {
+		return rec, true
+	}
+
+	byName := make(map[string][]int)
+	var names []string
+	for i, item := range r.Items {
+		name := strings.ToLower(strings.Join(strings.Fields(item.Name), " "))
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], i)
+		if item.Price < 0 && item.DiscountOf == nil && item.Owner != WholeReceipt {
+			rec.UnpairedDiscounts = append(rec.UnpairedDiscounts, i)
+		}
+		if diff := rec.Difference(); item.Price == diff || item.Price == -diff {
+			rec.Matches = append(rec.Matches, i)
+		}
+	}
+	for _, name := range names {
+		if len(byName[name]) > 1 {
+			rec.Duplicates = append(rec.Duplicates, byName[name])
+		}
+	}
+	return rec, true
+}
+
+// Difference is the computed total minus the printed total.
+func (r *Reconciliation) Difference() PriceInCents {
+	return r.Computed - r.Printed
+}
+
+// IsReconciled tells whether expenses can be created for the receipt, i.e.
+// the printed total is unknown, matches the items, or the difference was
+// explicitly accepted.
+func (r *Receipt) IsReconciled() bool {
+	rec, ok := r.Reconcile()
+	return !ok || rec.Difference() == 0 || r.TotalOverridden
+}
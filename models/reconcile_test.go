@@ -0,0 +1,84 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	total := func(p models.PriceInCents) *models.PriceInCents { return &p }
+	for _, tt := range []struct {
+		name       string
+		receipt    *models.Receipt
+		expected   *models.Reconciliation
+		ok         bool
+		reconciled bool
+	}{
+		{
+			name:       "unknown total",
+			receipt:    newReceipt(&models.ReceiptItem{Name: "Milk", Price: 120}),
+			reconciled: true,
+		},
+		{
+			name: "matching total",
+			receipt: &models.Receipt{
+				Items: []*models.ReceiptItem{{Name: "Milk", Price: 120}, {Name: "Bread", Price: 95}},
+				Total: total(215),
+			},
+			expected:   &models.Reconciliation{Printed: 215, Computed: 215},
+			ok:         true,
+			reconciled: true,
+		},
+		{
+			name: "duplicated line",
+			receipt: &models.Receipt{
+				Items: []*models.ReceiptItem{{Name: "Milk", Price: 120}, {Name: "Bread", Price: 95}, {Name: "milk ", Price: 120}},
+				Total: total(215),
+			},
+			expected: &models.Reconciliation{
+				Printed:    215,
+				Computed:   335,
+				Duplicates: [][]int{{0, 2}},
+				Matches:    []int{0, 2},
+			},
+			ok: true,
+		},
+		{
+			name: "missed discount",
+			receipt: &models.Receipt{
+				Items: []*models.ReceiptItem{{Name: "Milk", Price: 120}, {Name: "Coupon", Price: -20}, {Name: "Bread", Price: 95}},
+				Total: total(175),
+			},
+			expected: &models.Reconciliation{
+				Printed:           175,
+				Computed:          195,
+				UnpairedDiscounts: []int{1},
+				Matches:           []int{1},
+			},
+			ok: true,
+		},
+		{
+			name: "overridden",
+			receipt: &models.Receipt{
+				Items:           []*models.ReceiptItem{{Name: "Milk", Price: 120}},
+				Total:           total(100),
+				TotalOverridden: true,
+			},
+			expected:   &models.Reconciliation{Printed: 100, Computed: 120},
+			ok:         true,
+			reconciled: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt := tt
+			t.Parallel()
+
+			actual, ok := tt.receipt.Reconcile()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.reconciled, tt.receipt.IsReconciled())
+		})
+	}
+}
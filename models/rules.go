@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type (
+	// Rule assigns the items whose names match the regular expression
+	// Pattern to Owner, e.g. "(?i)vegan|oat|tofu" to a member.
+	Rule struct {
+		Pattern string           `yaml:"pattern" json:"pattern"`
+		Owner   ReceiptItemOwner `yaml:"owner" json:"owner"`
+	}
+
+	// Rules are applied in order, the first rule matching an item wins.
+	Rules []Rule
+
+	// RuleMatch tells that the item at index Item was assigned by the rule
+	// at index Rule.
+	RuleMatch struct {
+		Item int
+		Rule int
+	}
+)
+
+// NewRule parses the owner, like ParseReceiptItemOwner, and checks the
+// pattern. Codes are other valid owners, like WholeReceipt.
+func NewRule(owner, pattern string, members []ReceiptItemOwner, codes ...ReceiptItemOwner) (Rule, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("empty pattern")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	o := ReceiptItemOwner(strings.TrimSpace(strings.ToLower(owner)))
+	if !o.In(codes) {
+		var ok bool
+		if o, ok = ParseReceiptItemOwner(owner, members); !ok {
+			return Rule{}, fmt.Errorf("invalid owner '%s'", owner)
+		}
+	}
+	return Rule{Pattern: pattern, Owner: o}, nil
+}
+
+// Validate checks the rules with NewRule and normalizes their owners.
+func (r Rules) Validate(members []ReceiptItemOwner, codes ...ReceiptItemOwner) error {
+	for i, rule := range r {
+		parsed, err := NewRule(string(rule.Owner), rule.Pattern, members, codes...)
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		r[i] = parsed
+	}
+	return nil
+}
+
+// Match returns the index of the first rule matching the name. Rules with
+// invalid patterns never match.
+func (r Rules) Match(name string) (int, bool) {
+	for i, rule := range r {
+		re, err := regexp.Compile(rule.Pattern)
+		if err == nil && re.MatchString(name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// ApplyRules assigns each pending item to the owner of the first rule
+// matching its name, and returns the assigned items.
+func (r *Receipt) ApplyRules(rules Rules) []RuleMatch {
+	var matches []RuleMatch
+	for i := 0; i < r.Len(); i++ {
+		if !r.IsPending(i) {
+			continue
+		}
+		if rule, ok := rules.Match(r.Items[i].Name); ok {
+			r.SetItemOwner(i, rules[rule].Owner, nil)
+			matches = append(matches, RuleMatch{Item: i, Rule: rule})
+		}
+	}
+	return matches
+}
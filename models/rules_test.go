@@ -0,0 +1,96 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRule(t *testing.T) {
+	members := []models.ReceiptItemOwner{"a", "m", "j"}
+	for _, tt := range []struct {
+		name     string
+		owner    string
+		pattern  string
+		expected models.Rule
+		err      string
+	}{
+		{
+			name:     "member",
+			owner:    "A",
+			pattern:  " (?i)vegan|oat|tofu ",
+			expected: models.Rule{Pattern: "(?i)vegan|oat|tofu", Owner: "a"},
+		},
+		{
+			name:     "subset is normalized",
+			owner:    "j+a",
+			pattern:  "wine",
+			expected: models.Rule{Pattern: "wine", Owner: "a+j"},
+		},
+		{
+			name:     "extra code",
+			owner:    "n",
+			pattern:  "deposit",
+			expected: models.Rule{Pattern: "deposit", Owner: "n"},
+		},
+		{
+			name:    "unknown owner",
+			owner:   "x",
+			pattern: "wine",
+			err:     "invalid owner 'x'",
+		},
+		{
+			name:    "invalid pattern",
+			owner:   "a",
+			pattern: "(oat",
+			err:     "invalid pattern",
+		},
+		{
+			name:  "empty pattern",
+			owner: "a",
+			err:   "empty pattern",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := models.NewRule(tt.owner, tt.pattern, members, "n")
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rule)
+		})
+	}
+
+	rules := models.Rules{{Pattern: "oat", Owner: "m+a"}, {Pattern: "(", Owner: "a"}}
+	err := rules.Validate(members)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule 2")
+	assert.Equal(t, models.ReceiptItemOwner("a+m"), rules[0].Owner)
+}
+
+func TestApplyRules(t *testing.T) {
+	discountOf := 1
+	receipt := &models.Receipt{Items: []*models.ReceiptItem{
+		{Name: "Bread", Price: 95},
+		{Name: "Oat Milk", Price: 199},
+		{Name: "Oat Milk Discount", Price: -50, DiscountOf: &discountOf},
+		{Name: "Vegan Tofu", Price: 250, Owner: "m"},
+		{Name: "Bag fee", Price: 10},
+	}}
+	rules := models.Rules{
+		{Pattern: "(?i)vegan|oat|tofu", Owner: "a"},
+		{Pattern: "deposit|Bag fee", Owner: models.Shared},
+		{Pattern: "(?i)milk", Owner: "m"},
+	}
+
+	matches := receipt.ApplyRules(rules)
+	assert.Equal(t, []models.RuleMatch{{Item: 1, Rule: 0}, {Item: 4, Rule: 1}}, matches)
+	assert.True(t, receipt.IsPending(0))
+	assert.Equal(t, models.ReceiptItemOwner("a"), receipt.Items[2].Owner)
+	assert.Equal(t, models.ReceiptItemOwner("m"), receipt.Items[3].Owner)
+	assert.Equal(t, models.Shared, receipt.Items[4].Owner)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ReceiptSchema returns the JSON schema of the receipts extracted by models,
+// generated from the fields of Receipt and ReceiptItem with a schema tag.
+// The tag is the description of the field. All the fields are required, as
+// strict function calling needs, and the ones with omitempty are nullable.
+func ReceiptSchema() map[string]interface{} {
+	return schemaOf(reflect.TypeOf(Receipt{}), "")
+}
+
+func schemaOf(t reflect.Type, description string) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := make(map[string]interface{})
+	switch t.Kind() {
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = schemaOf(t.Elem(), "")
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			desc, ok := field.Tag.Lookup("schema")
+			if !ok {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			property := schemaOf(field.Type, desc)
+			if opts == "omitempty" {
+				property["type"] = []string{property["type"].(string), "null"}
+			}
+			properties[name] = property
+			required = append(required, name)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["required"] = required
+		schema["additionalProperties"] = false
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	return schema
+}
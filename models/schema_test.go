@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptSchema(t *testing.T) {
+	b, err := json.Marshal(models.ReceiptSchema())
+	require.NoError(t, err)
+	var schema struct {
+		Type       string   `json:"type"`
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type  interface{} `json:"type"`
+			Items struct {
+				Type       string                     `json:"type"`
+				Required   []string                   `json:"required"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"items"`
+		} `json:"properties"`
+		AdditionalProperties bool `json:"additionalProperties"`
+	}
+	require.NoError(t, json.Unmarshal(b, &schema))
+
+	assert.Equal(t, "object", schema.Type)
+	assert.False(t, schema.AdditionalProperties)
+	assert.Equal(t, []string{"items", "currency", "store", "date", "total", "payment_method"}, schema.Required)
+	assert.Len(t, schema.Properties, 6)
+	assert.Equal(t, "string", schema.Properties["currency"].Type)
+	assert.Equal(t, []interface{}{"integer", "null"}, schema.Properties["total"].Type)
+	assert.Equal(t, []interface{}{"string", "null"}, schema.Properties["date"].Type)
+	items := schema.Properties["items"]
+	assert.Equal(t, "array", items.Type)
+	assert.Equal(t, "object", items.Items.Type)
+	assert.Equal(t, []string{"name", "price", "quantity", "unit_price"}, items.Items.Required)
+	assert.Len(t, items.Items.Properties, 4)
+	assert.NotContains(t, items.Items.Properties, "owner")
+}
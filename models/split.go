@@ -0,0 +1,57 @@
+package models
+
+import (
+	"math/big"
+	"sort"
+)
+
+// allocate splits amount into len(weights) parts proportional to weights.
+// See roundShares for the rounding rules.
+func allocate(amount PriceInCents, weights []int64) []PriceInCents {
+	var totalWeight int64
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		return make([]PriceInCents, len(weights))
+	}
+	shares := make([]*big.Rat, len(weights))
+	for i, w := range weights {
+		shares[i] = big.NewRat(int64(amount)*w, totalWeight)
+	}
+	return roundShares(shares)
+}
+
+// roundShares rounds exact shares whose sum is a whole number of cents
+// using the largest remainder method. Every share is rounded down and the
+// missing cents go to the shares with the largest fractional parts. Ties are
+// broken by the lowest index, so the result is deterministic and always sums
+// up to the sum of the exact shares.
+func roundShares(shares []*big.Rat) []PriceInCents {
+	parts := make([]PriceInCents, len(shares))
+	fracs := make([]*big.Rat, len(shares))
+	sum := new(big.Rat)
+	var floorSum PriceInCents
+	for i, share := range shares {
+		floor := new(big.Int).Div(share.Num(), share.Denom())
+		parts[i] = PriceInCents(floor.Int64())
+		fracs[i] = new(big.Rat).Sub(share, new(big.Rat).SetInt(floor))
+		sum.Add(sum, share)
+		floorSum += parts[i]
+	}
+	total := new(big.Int).Div(sum.Num(), sum.Denom())
+	leftover := PriceInCents(total.Int64()) - floorSum
+
+	idxs := make([]int, len(shares))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(a, b int) bool {
+		return fracs[idxs[a]].Cmp(fracs[idxs[b]]) > 0
+	})
+	for i := 0; leftover > 0 && i < len(idxs); i++ {
+		parts[idxs[i]]++
+		leftover--
+	}
+	return parts
+}
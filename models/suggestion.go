@@ -0,0 +1,28 @@
+package models
+
+// SuggestItemOwner sets a suggested owner of the item at index i and of its
+// linked discounts. The item is no longer pending, but it is listed by
+// Suggested until the suggestion is accepted.
+func (r *Receipt) SuggestItemOwner(i int, owner ReceiptItemOwner, confidence float64) {
+	r.SetItemOwner(i, owner, nil)
+	r.Items[i].Confidence = confidence
+}
+
+// Suggested returns the indexes of the items with suggested owners that
+// were not accepted yet.
+func (r *Receipt) Suggested() []int {
+	var suggested []int
+	for i := 0; i < r.Len(); i++ {
+		if r.Items[i].Confidence > 0 {
+			suggested = append(suggested, i)
+		}
+	}
+	return suggested
+}
+
+// AcceptSuggestions makes the suggested owners decisions.
+func (r *Receipt) AcceptSuggestions() {
+	for i := 0; i < r.Len(); i++ {
+		r.Items[i].Confidence = 0
+	}
+}
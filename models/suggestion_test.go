@@ -0,0 +1,31 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestItemOwner(t *testing.T) {
+	discountOf := 0
+	receipt := &models.Receipt{Items: []*models.ReceiptItem{
+		{Name: "Oat Milk", Price: 199},
+		{Name: "Oat Milk Discount", Price: -50, DiscountOf: &discountOf},
+		{Name: "Bread", Price: 95},
+	}}
+
+	receipt.SuggestItemOwner(0, "a", 0.9)
+	assert.False(t, receipt.IsPending(0))
+	assert.Equal(t, models.ReceiptItemOwner("a"), receipt.Items[1].Owner)
+	assert.True(t, receipt.IsPending(2))
+	assert.Equal(t, []int{0}, receipt.Suggested())
+
+	receipt.SuggestItemOwner(2, "s", 0.5)
+	receipt.SetItemOwner(2, "m", nil)
+	assert.Equal(t, []int{0}, receipt.Suggested())
+
+	receipt.AcceptSuggestions()
+	assert.Empty(t, receipt.Suggested())
+	assert.Equal(t, models.ReceiptItemOwner("a"), receipt.Items[0].Owner)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// ValidationError lists the problems of an invalid receipt.
+	ValidationError struct {
+		Problems []string
+	}
+)
+
+const (
+	// maxMajorUnits is the largest sane absolute price of an item or total
+	// in the major unit of the currency.
+	maxMajorUnits = 100000
+)
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid receipt: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks that the receipt looks like a real one, like the ones
+// extracted by models: it has items with names and prices in a sane range,
+// the quantities match the prices, and the metadata can be parsed. It
+// returns a *ValidationError with all the problems found.
+func (r *Receipt) Validate() error {
+	var problems []string
+	if len(r.Items) == 0 {
+		problems = append(problems, "the receipt has no items")
+	}
+	if r.Currency != "" {
+		if _, ok := ParseCurrency(string(r.Currency)); !ok {
+			problems = append(problems, fmt.Sprintf("currency '%s' is not an ISO 4217 code", r.Currency))
+		}
+	}
+	maxPrice := PriceInCents(maxMajorUnits * pow10(r.Currency.OrDefault().MinorUnits()))
+	for i, item := range r.Items {
+		if item == nil {
+			problems = append(problems, fmt.Sprintf("item %d is null", i+1))
+			continue
+		}
+		if strings.TrimSpace(item.Name) == "" {
+			problems = append(problems, fmt.Sprintf("item %d has no name", i+1))
+		}
+		if item.Price > maxPrice || item.Price < -maxPrice {
+			problems = append(problems, fmt.Sprintf("price %d of item %d (%s) is out of range, prices must be in the minor unit of the currency", item.Price, i+1, item.Name))
+		}
+		if item.Quantity < 0 {
+			problems = append(problems, fmt.Sprintf("quantity %d of item %d (%s) is negative", item.Quantity, i+1, item.Name))
+		}
+		if item.DiscountOf != nil && (*item.DiscountOf < 0 || *item.DiscountOf >= len(r.Items) || *item.DiscountOf == i) {
+			problems = append(problems, fmt.Sprintf("item %d (%s) is a discount of item %d, which does not exist", i+1, item.Name, *item.DiscountOf+1))
+		}
+		if item.Quantity > 0 && item.UnitPrice != 0 && item.Price != 0 && PriceInCents(item.Quantity)*item.UnitPrice != item.Price {
+			problems = append(problems, fmt.Sprintf("quantity %d times unit price %d of item %d (%s) is not its price %d", item.Quantity, item.UnitPrice, i+1, item.Name, item.Price))
+		}
+	}
+	if r.Date != "" {
+		if _, ok := r.PurchaseDate(time.UTC); !ok {
+			problems = append(problems, fmt.Sprintf("date '%s' is not in the format YYYY-MM-DD or YYYY-MM-DD HH:MM", r.Date))
+		}
+	}
+	if r.Total != nil && (*r.Total < 0 || *r.Total > maxPrice) {
+		problems = append(problems, fmt.Sprintf("total %d is out of range, it must be in the minor unit of the currency", *r.Total))
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
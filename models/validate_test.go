@@ -0,0 +1,91 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	total := func(p models.PriceInCents) *models.PriceInCents { return &p }
+	index := func(i int) *int { return &i }
+	for _, tt := range []struct {
+		name     string
+		receipt  *models.Receipt
+		problems []string
+	}{
+		{
+			name: "valid",
+			receipt: &models.Receipt{
+				Items: []*models.ReceiptItem{
+					{Name: "Cola", Price: 620, Quantity: 2, UnitPrice: 310},
+					{Name: "Discount", Price: -100},
+				},
+				Currency: "GBP",
+				Date:     "2024-03-09 18:42",
+				Total:    total(520),
+			},
+		},
+		{
+			name:     "no items",
+			receipt:  &models.Receipt{},
+			problems: []string{"the receipt has no items"},
+		},
+		{
+			name: "invalid items",
+			receipt: &models.Receipt{
+				Items: []*models.ReceiptItem{
+					{Name: " ", Price: 100},
+					nil,
+					{Name: "TV", Price: 100000000},
+					{Name: "Cola", Price: 600, Quantity: 2, UnitPrice: 310},
+					{Name: "Eggs", Price: 300, Quantity: -1},
+					{Name: "Discount", Price: -50, DiscountOf: index(41)},
+				},
+			},
+			problems: []string{
+				"item 1 has no name",
+				"item 2 is null",
+				"price 100000000 of item 3 (TV) is out of range, prices must be in the minor unit of the currency",
+				"quantity 2 times unit price 310 of item 4 (Cola) is not its price 600",
+				"quantity -1 of item 5 (Eggs) is negative",
+				"item 6 (Discount) is a discount of item 42, which does not exist",
+			},
+		},
+		{
+			name: "price range of currency without minor unit",
+			receipt: &models.Receipt{
+				Items:    []*models.ReceiptItem{{Name: "Sushi", Price: 1000000}},
+				Currency: "JPY",
+			},
+			problems: []string{"price 1000000 of item 1 (Sushi) is out of range, prices must be in the minor unit of the currency"},
+		},
+		{
+			name: "invalid metadata",
+			receipt: &models.Receipt{
+				Items:    []*models.ReceiptItem{{Name: "Milk", Price: 120}},
+				Currency: "euro",
+				Date:     "09/03/2024",
+				Total:    total(-120),
+			},
+			problems: []string{
+				"currency 'euro' is not an ISO 4217 code",
+				"date '09/03/2024' is not in the format YYYY-MM-DD or YYYY-MM-DD HH:MM",
+				"total -120 is out of range, it must be in the minor unit of the currency",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.receipt.Validate()
+			if tt.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *models.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.problems, validationErr.Problems)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// ReceiptItemWeights are the relative shares of the members that split
+	// an item unevenly.
+	ReceiptItemWeights map[ReceiptItemOwner]int64
+)
+
+const (
+	// percentScale is the weight of 1%, so percentages with up to two
+	// decimal places are stored as integers.
+	percentScale = 100
+)
+
+var (
+	regexWeightToken = regexp.MustCompile(`^([^=]+)=([0-9]+)(\.([0-9]{1,2}))?(%?)$`)
+)
+
+// ParseReceiptItemWeights parses weights like "a=2 m=1" or "a=70% m=30%"
+// and returns the owner formed by the weighted members along with the
+// weights. Percentages must add up to 100% and cannot be mixed with plain
+// weights.
+func ParseReceiptItemWeights(s string, members []ReceiptItemOwner) (ReceiptItemOwner, ReceiptItemWeights, error) {
+	weights := make(ReceiptItemWeights)
+	var codes []string
+	var percents, plain int
+	var percentTotal int64
+	for _, tok := range regexSpaces.Split(strings.TrimSpace(strings.ToLower(s)), -1) {
+		if tok == "" {
+			continue
+		}
+		m := regexWeightToken.FindStringSubmatch(tok)
+		if m == nil {
+			return "", nil, fmt.Errorf("'%s' is not in the format <code>=<weight> or <code>=<percentage>%%", tok)
+		}
+		owner := ReceiptItemOwner(m[1])
+		if !owner.In(members) {
+			return "", nil, fmt.Errorf("'%s' is not a member", owner)
+		}
+		if _, ok := weights[owner]; ok {
+			return "", nil, fmt.Errorf("'%s' appears more than once", owner)
+		}
+		intPart, _ := strconv.ParseInt(m[2], 10, 64)
+		var weight int64
+		if m[5] == "%" {
+			decimals := m[4]
+			for len(decimals) < 2 {
+				decimals += "0"
+			}
+			fracPart, _ := strconv.ParseInt(decimals, 10, 64)
+			weight = intPart*percentScale + fracPart
+			percentTotal += weight
+			percents++
+		} else {
+			if m[3] != "" {
+				return "", nil, fmt.Errorf("weight '%s' must be a whole number", tok)
+			}
+			weight = intPart
+			plain++
+		}
+		if weight <= 0 {
+			return "", nil, fmt.Errorf("weight of '%s' must be positive", owner)
+		}
+		weights[owner] = weight
+		codes = append(codes, string(owner))
+	}
+	switch {
+	case len(weights) < 2:
+		return "", nil, errors.New("at least two members must be weighted")
+	case percents > 0 && plain > 0:
+		return "", nil, errors.New("percentages and plain weights cannot be mixed")
+	case percents > 0 && percentTotal != 100*percentScale:
+		return "", nil, fmt.Errorf("percentages add up to %s%%, not 100%%", formatPercent(percentTotal))
+	}
+	owner, _ := ParseReceiptItemOwner(strings.Join(codes, ownerSeparator), members)
+	return owner, weights, nil
+}
+
+// Of returns the weight of the given member of the subset owning the item.
+// Items without weights are split evenly.
+func (w ReceiptItemWeights) Of(member ReceiptItemOwner) int64 {
+	if len(w) == 0 {
+		return 1
+	}
+	return w[member]
+}
+
+func formatPercent(p int64) string {
+	s := fmt.Sprintf("%d", p/percentScale)
+	if frac := p % percentScale; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+	}
+	return s
+}
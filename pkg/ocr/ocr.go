@@ -0,0 +1,58 @@
+// Package ocr reads the text of images of receipts offline with Tesseract.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultBinary is the Tesseract command line tool.
+	DefaultBinary = "tesseract"
+)
+
+// Recognize returns the text of the images, in order, read with the given
+// tesseract-compatible binary, or DefaultBinary if empty. The languages are
+// Tesseract language codes like "eng+deu", or empty for Tesseract's default.
+func Recognize(ctx context.Context, images [][]byte, binary, languages string) (string, error) {
+	if binary == "" {
+		binary = DefaultBinary
+	}
+	dir, err := os.MkdirTemp("", "splitwiser-ocr-")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	texts := make([]string, 0, len(images))
+	for i, image := range images {
+		input := filepath.Join(dir, fmt.Sprintf("image-%d", i+1))
+		if err := os.WriteFile(input, image, 0o600); err != nil {
+			return "", fmt.Errorf("error writing temporary image file: %w", err)
+		}
+		args := []string{input, "stdout"}
+		if languages != "" {
+			args = append(args, "-l", languages)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, binary, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return "", fmt.Errorf("OCR binary '%s' is not installed: %w", binary, err)
+			}
+			return "", fmt.Errorf("error running OCR binary on image %d: %w: %s", i+1, err, stderr.String())
+		}
+		if text := strings.TrimSpace(stdout.String()); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
@@ -0,0 +1,64 @@
+package ocr_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/pkg/ocr"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTesseract writes a script standing in for tesseract.
+func stubTesseract(t *testing.T, script string) string {
+	t.Helper()
+	stub := filepath.Join(t.TempDir(), "tesseract")
+	require.NoError(t, os.WriteFile(stub, []byte(script), 0o755))
+	return stub
+}
+
+func TestRecognize(t *testing.T) {
+	// the "images" are text, so the stub prints them followed by the languages
+	stub := stubTesseract(t, `#!/bin/sh
+[ "$2" = stdout ] || exit 1
+cat "$1"
+shift 2
+[ $# -gt 0 ] && echo "$@"
+exit 0
+`)
+	images := [][]byte{
+		[]byte("TESCO\nMilk 1.20\n"),
+		[]byte("   \n"),
+		[]byte("Bread 0.95\nTOTAL 2.15\n"),
+	}
+
+	text, err := ocr.Recognize(context.Background(), images, stub, "")
+	require.NoError(t, err)
+	assert.Equal(t, "TESCO\nMilk 1.20\nBread 0.95\nTOTAL 2.15", text)
+
+	receipt, unparsed := models.ParseReceipt(text)
+	assert.Equal(t, 2, receipt.Len())
+	assert.Equal(t, []string{"TESCO"}, unparsed)
+
+	text, err = ocr.Recognize(context.Background(), images[:1], stub, "eng+deu")
+	require.NoError(t, err)
+	assert.Equal(t, "TESCO\nMilk 1.20\n-l eng+deu", text)
+}
+
+func TestRecognizeErrors(t *testing.T) {
+	failing := stubTesseract(t, `#!/bin/sh
+echo "Error in pixReadStream" >&2
+exit 1
+`)
+	_, err := ocr.Recognize(context.Background(), [][]byte{[]byte("image")}, failing, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error running OCR binary on image 1")
+	assert.Contains(t, err.Error(), "Error in pixReadStream")
+
+	_, err = ocr.Recognize(context.Background(), [][]byte{[]byte("image")}, filepath.Join(t.TempDir(), "missing"), "")
+	assert.Error(t, err)
+}
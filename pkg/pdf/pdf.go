@@ -0,0 +1,76 @@
+// Package pdf extracts the text layer of PDF files, like the e-receipts of
+// supermarkets and delivery apps, and rasterizes the pages of scanned ones,
+// with the command line tools of Poppler.
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultTextExtractor is the Poppler tool for extracting the text of
+	// PDF files.
+	DefaultTextExtractor = "pdftotext"
+)
+
+// ExtractText returns the text layer of the PDF with the given
+// pdftotext-compatible binary, or DefaultTextExtractor if empty. The text
+// keeps the physical layout of the pages, so the names and prices of the
+// items stay on the same line. It returns an empty string if the PDF has no
+// text, like scanned documents.
+func ExtractText(ctx context.Context, b []byte, extractor string) (string, error) {
+	if extractor == "" {
+		extractor = DefaultTextExtractor
+	}
+	dir, input, err := writeTemp(b)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	out, err := run(ctx, "PDF text extractor", extractor, "-layout", input, "-")
+	if err != nil {
+		return "", err
+	}
+	// pdftotext ends every page with a form feed
+	text := strings.ReplaceAll(string(out), "\f", "\n")
+	return strings.TrimSpace(text), nil
+}
+
+// writeTemp writes the PDF to a new temporary directory, which the caller
+// must remove.
+func writeTemp(b []byte) (dir, input string, err error) {
+	dir, err = os.MkdirTemp("", "splitwiser-pdf-")
+	if err != nil {
+		return "", "", fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	input = filepath.Join(dir, "input.pdf")
+	if err := os.WriteFile(input, b, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", "", fmt.Errorf("error writing temporary PDF file: %w", err)
+	}
+	return dir, input, nil
+}
+
+// run runs the binary of a tool, named by kind in the errors, and returns
+// its standard output.
+func run(ctx context.Context, kind, binary string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s '%s' is not installed: %w", kind, binary, err)
+		}
+		return nil, fmt.Errorf("error running %s: %w: %s", kind, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package pdf_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/pkg/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractText(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// script is the body of the stub standing for pdftotext
+		script   string
+		expected string
+		err      string
+	}{
+		{
+			name:   "two pages",
+			script: `printf '        ALBERT HEIJN\n\nMelk halfvol          1,19\nKaas jong belegen     4,50\n\fTOTAAL               €5,69\n\f'`,
+			expected: `ALBERT HEIJN
+
+Melk halfvol          1,19
+Kaas jong belegen     4,50
+
+TOTAAL               €5,69`,
+		},
+		{
+			// scanned receipt, only an image
+			name:     "no text",
+			script:   `printf '\f\f'`,
+			expected: "",
+		},
+		{
+			name:   "failure",
+			script: `echo "Syntax Error: Couldn't find trailer dictionary" >&2; exit 1`,
+			err:    "error running PDF text extractor: exit status 1: Syntax Error: Couldn't find trailer dictionary\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// the stub checks the arguments pdftotext is called with
+			stub := filepath.Join(t.TempDir(), "pdftotext")
+			script := `#!/bin/sh
+[ "$1" = -layout ] && [ -f "$2" ] && [ "$3" = - ] || exit 2
+` + tt.script + "\n"
+			require.NoError(t, os.WriteFile(stub, []byte(script), 0o755))
+
+			b, err := os.ReadFile(filepath.Join("testdata", "scan.pdf"))
+			require.NoError(t, err)
+			text, err := pdf.ExtractText(context.Background(), b, stub)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
+
+func TestExtractTextMissingBinary(t *testing.T) {
+	_, err := pdf.ExtractText(context.Background(), []byte("%PDF-1.4"), filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestRasterize(t *testing.T) {
+	// the stub writes two pages named like pdftoppm does
+	stub := filepath.Join(t.TempDir(), "pdftoppm")
+	script := `#!/bin/sh
+for last; do :; done
+printf page2 > "$last-2.png"
+printf page1 > "$last-1.png"
+`
+	require.NoError(t, os.WriteFile(stub, []byte(script), 0o755))
+
+	b, err := os.ReadFile(filepath.Join("testdata", "scan.pdf"))
+	require.NoError(t, err)
+	pages, err := pdf.Rasterize(context.Background(), b, stub)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("page1"), []byte("page2")}, pages)
+
+	_, err = pdf.Rasterize(context.Background(), b, filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
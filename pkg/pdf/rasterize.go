@@ -0,0 +1,53 @@
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// DefaultRasterizer is the Poppler tool for rendering PDF pages.
+	DefaultRasterizer = "pdftoppm"
+
+	rasterizerDPI = "150"
+)
+
+// Rasterize renders the pages of the PDF as PNG images with the given
+// pdftoppm-compatible binary, or DefaultRasterizer if empty.
+func Rasterize(ctx context.Context, b []byte, rasterizer string) ([][]byte, error) {
+	if rasterizer == "" {
+		rasterizer = DefaultRasterizer
+	}
+	dir, input, err := writeTemp(b)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := run(ctx, "PDF rasterizer", rasterizer, "-png", "-r", rasterizerDPI, input, filepath.Join(dir, "page")); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing rasterized pages: %w", err)
+	}
+	// pdftoppm pads the page numbers with zeros, so they sort as strings
+	sort.Strings(files)
+	pages := make([][]byte, 0, len(files))
+	for _, file := range files {
+		page, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading rasterized page: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("PDF rasterizer produced no pages")
+	}
+	return pages, nil
+}
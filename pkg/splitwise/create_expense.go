@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/matheuscscp/splitwiser/config"
 	"github.com/matheuscscp/splitwiser/models"
@@ -21,13 +22,14 @@ type (
 	}
 
 	client struct {
-		conf *config.Splitwise
+		conf    *config.Splitwise
+		members config.Members
 	}
 )
 
 // NewClient ...
-func NewClient(conf *config.Splitwise) Client {
-	return &client{conf: conf}
+func NewClient(conf *config.Splitwise, members config.Members) Client {
+	return &client{conf: conf, members: members}
 }
 
 func (c *client) CreateExpense(ctx context.Context, expense *models.Expense, storeName string) string {
@@ -40,21 +42,27 @@ func (c *client) CreateExpense(ctx context.Context, expense *models.Expense, sto
 	}
 
 	// create payload
+	payload := map[string]interface{}{
+		"currency_code": string(expense.Currency.OrDefault()),
+		"category_id":   12, // Groceries
+		"description":   fmt.Sprintf("%s %s", storeName, expense.Description),
+		"cost":          expense.Currency.OrDefault().Format(expense.Cost),
+		"group_id":      c.conf.GroupID,
+	}
+	if !expense.Date.IsZero() {
+		payload["date"] = expense.Date.Format(time.RFC3339)
+	}
+	for i, share := range expense.UserShares {
+		member, ok := c.members.Get(share.User)
+		if !ok {
+			return fmt.Sprintf("Unknown member '%s' in expense.", share.User)
+		}
+		payload[fmt.Sprintf("users__%d__user_id", i)] = member.SplitwiseUserID
+		payload[fmt.Sprintf("users__%d__paid_share", i)] = expense.Currency.OrDefault().Format(share.Paid)
+		payload[fmt.Sprintf("users__%d__owed_share", i)] = expense.Currency.OrDefault().Format(share.Owed)
+	}
 	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(map[string]interface{}{
-		"currency_code":        "EUR",
-		"category_id":          12, // Groceries
-		"description":          fmt.Sprintf("%s %s", storeName, expense.Description),
-		"cost":                 expense.Cost.String(),
-		"group_id":             c.conf.GroupID,
-		"users__0__user_id":    c.conf.GetUserID(expense.UserShares[0].User),
-		"users__0__paid_share": expense.UserShares[0].Paid.String(),
-		"users__0__owed_share": expense.UserShares[0].Owed.String(),
-		"users__1__user_id":    c.conf.GetUserID(expense.UserShares[1].User),
-		"users__1__paid_share": expense.UserShares[1].Paid.String(),
-		"users__1__owed_share": expense.UserShares[1].Owed.String(),
-	})
-	if err != nil {
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
 		return fmt.Sprintf("Error encoding Splitwise JSON body: %v", err)
 	}
 
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/matheuscscp/splitwiser/services/objects"
+)
+
+type (
+	// Service stores values by content-addressed keys, see Key.
+	Service interface {
+		Store(ctx context.Context, key string, v interface{}) error
+		Load(ctx context.Context, key string, v interface{}) error
+	}
+
+	service struct {
+		store objects.Store
+	}
+)
+
+const (
+	prefix = "cache/"
+)
+
+var (
+	// ErrCacheMiss ...
+	ErrCacheMiss = errors.New("value not found in the cache")
+)
+
+// Key returns the SHA-256 of the contents, e.g. the images of a receipt, in
+// hex. The length of each content is hashed too, so splitting the same bytes
+// differently gives a different key.
+func Key(contents ...[]byte) string {
+	h := sha256.New()
+	for _, b := range contents {
+		binary.Write(h, binary.BigEndian, uint64(len(b)))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewService ...
+func NewService(store objects.Store) Service {
+	return &service{store: store}
+}
+
+func (s *service) Store(ctx context.Context, key string, v interface{}) error {
+	if err := s.store.Store(ctx, prefix+key, v); err != nil {
+		return fmt.Errorf("error storing cache value: %w", err)
+	}
+	return nil
+}
+
+func (s *service) Load(ctx context.Context, key string, v interface{}) error {
+	if err := s.store.Load(ctx, prefix+key, v); err != nil {
+		if errors.Is(err, objects.ErrNotExist) {
+			return ErrCacheMiss
+		}
+		return fmt.Errorf("error loading cache value: %w", err)
+	}
+	return nil
+}
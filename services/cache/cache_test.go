@@ -0,0 +1,46 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/cache"
+	"github.com/matheuscscp/splitwiser/services/objects"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	key := cache.Key([]byte("page1"), []byte("page2"))
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, cache.Key([]byte("page1"), []byte("page2")))
+	assert.NotEqual(t, key, cache.Key([]byte("page2"), []byte("page1")))
+	assert.NotEqual(t, key, cache.Key([]byte("page1page2")))
+	assert.NotEqual(t, key, cache.Key([]byte("page1pag"), []byte("e2")))
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	store, err := objects.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	s := cache.NewService(store)
+
+	key := cache.Key([]byte("photo"))
+	var receipt *models.Receipt
+	assert.ErrorIs(t, s.Load(ctx, key, &receipt), cache.ErrCacheMiss)
+
+	stored := &models.Receipt{Items: []*models.ReceiptItem{{Name: "Milk", Price: 120}}, Currency: "EUR"}
+	require.NoError(t, s.Store(ctx, key, stored))
+	require.NoError(t, s.Load(ctx, key, &receipt))
+	assert.Equal(t, stored, receipt)
+}
+
+func TestDisabledService(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewService(objects.Disabled)
+	require.NoError(t, s.Store(ctx, "key", "value"))
+	var v string
+	assert.ErrorIs(t, s.Load(ctx, "key", &v), cache.ErrCacheMiss)
+}
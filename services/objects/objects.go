@@ -0,0 +1,237 @@
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+type (
+	// Store reads and writes JSON objects by name, like "usage.json". It is
+	// shared by the services persisting state, see NewStore.
+	Store interface {
+		// Load unmarshals the object into v, or returns ErrNotExist.
+		Load(ctx context.Context, name string, v interface{}) error
+		// Store replaces the object with v marshaled.
+		Store(ctx context.Context, name string, v interface{}) error
+		// Delete deletes the object, if it exists.
+		Delete(ctx context.Context, name string) error
+	}
+
+	bucketStore struct {
+		bucket *storage.BucketHandle
+	}
+
+	fileStore struct {
+		dir string
+		mu  sync.Mutex
+	}
+
+	// memoryStore keeps the objects only while the bot runs.
+	memoryStore struct {
+		objects map[string][]byte
+		mu      sync.Mutex
+	}
+
+	disabledStore struct{}
+)
+
+const (
+	// ProviderBucket stores the objects in a cloud storage bucket.
+	ProviderBucket = "bucket"
+	// ProviderFile stores the objects as files in a directory.
+	ProviderFile = "file"
+)
+
+var (
+	// ErrNotExist ...
+	ErrNotExist = errors.New("object does not exist")
+
+	// Disabled stores nothing and has no objects.
+	Disabled Store = disabledStore{}
+)
+
+// NewStore returns the store of the provider and a function closing it.
+// The bucket provider creates a single cloud storage client, so a store
+// should be created once and shared.
+func NewStore(ctx context.Context, provider, bucket, dir string) (Store, func(), error) {
+	switch provider {
+	case "", ProviderBucket:
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating cloud storage client: %w", err)
+		}
+		bktClient := client.Bucket(bucket)
+		if _, err := bktClient.Attrs(ctx); err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("error creating cloud storage bucket client: %w", err)
+		}
+		return &bucketStore{bucket: bktClient}, func() { client.Close() }, nil
+	case ProviderFile:
+		s, err := NewFileStore(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage provider '%s'", provider)
+	}
+}
+
+// NewFileStore returns a store keeping each object in a file of the
+// directory, named like the object.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating storage directory '%s': %w", dir, err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// NewMemoryStore returns a store keeping the objects only in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{objects: make(map[string][]byte)}
+}
+
+func (s *bucketStore) Load(ctx context.Context, name string, v interface{}) error {
+	r, err := s.bucket.Object(name).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("error creating reader of object '%s': %w", name, err)
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("error unmarshaling object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *bucketStore) Store(ctx context.Context, name string, v interface{}) error {
+	w := s.bucket.Object(name).NewWriter(ctx)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		w.Close()
+		return fmt.Errorf("error marshaling object '%s': %w", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error closing writer of object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *bucketStore) Delete(ctx context.Context, name string) error {
+	if err := s.bucket.Object(name).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("error deleting object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *fileStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+func (s *fileStore) Load(ctx context.Context, name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotExist
+		}
+		return fmt.Errorf("error reading object '%s': %w", name, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("error unmarshaling object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *fileStore) Store(ctx context.Context, name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling object '%s': %w", name, err)
+	}
+	path := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating directory of object '%s': %w", name, err)
+	}
+	// write and rename, so crashed runs don't leave partial objects behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("error writing object '%s': %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error renaming object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *memoryStore) Load(ctx context.Context, name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.objects[name]
+	if !ok {
+		return ErrNotExist
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("error unmarshaling object '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (s *memoryStore) Store(ctx context.Context, name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling object '%s': %w", name, err)
+	}
+	s.objects[name] = b
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, name)
+	return nil
+}
+
+func (disabledStore) Load(context.Context, string, interface{}) error {
+	return ErrNotExist
+}
+
+func (disabledStore) Store(context.Context, string, interface{}) error {
+	return nil
+}
+
+func (disabledStore) Delete(context.Context, string) error {
+	return nil
+}
+
+// Update loads the object into v, which is left as is if the object does
+// not exist, applies update to it and stores it back.
+func Update(ctx context.Context, s Store, name string, v interface{}, update func()) error {
+	if err := s.Load(ctx, name, v); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	update()
+	return s.Store(ctx, name, v)
+}
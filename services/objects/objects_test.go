@@ -0,0 +1,50 @@
+package objects_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/services/objects"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	fileStore, err := objects.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name  string
+		store objects.Store
+	}{
+		{name: "file", store: fileStore},
+		{name: "memory", store: objects.NewMemoryStore()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]int
+			assert.ErrorIs(t, tt.store.Load(ctx, "cache/counts.json", &v), objects.ErrNotExist)
+
+			for i := 0; i < 2; i++ {
+				counts := map[string]int{}
+				require.NoError(t, objects.Update(ctx, tt.store, "cache/counts.json", &counts, func() { counts["milk"]++ }))
+			}
+			require.NoError(t, tt.store.Load(ctx, "cache/counts.json", &v))
+			assert.Equal(t, map[string]int{"milk": 2}, v)
+
+			require.NoError(t, tt.store.Delete(ctx, "cache/counts.json"))
+			require.NoError(t, tt.store.Delete(ctx, "cache/counts.json"))
+			assert.ErrorIs(t, tt.store.Load(ctx, "cache/counts.json", &v), objects.ErrNotExist)
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, objects.Disabled.Store(ctx, "usage.json", 1))
+	var v int
+	assert.ErrorIs(t, objects.Disabled.Load(ctx, "usage.json", &v), objects.ErrNotExist)
+
+	_, _, err := objects.NewStore(ctx, "redis", "", "")
+	assert.EqualError(t, err, "unknown storage provider 'redis'")
+}
@@ -0,0 +1,139 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/matheuscscp/splitwiser/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Service ...
+	Service interface {
+		// Rate returns the amount of the currency to that one unit of the
+		// currency from is worth.
+		Rate(ctx context.Context, from, to models.Currency) (*big.Rat, error)
+	}
+
+	fileService struct {
+		base  models.Currency
+		rates map[models.Currency]*big.Rat
+	}
+
+	frankfurterService struct {
+		baseURL string
+	}
+)
+
+const (
+	// ProviderFile reads the rates from a YAML file.
+	ProviderFile = "file"
+	// ProviderFrankfurter fetches the rates from the Frankfurter API.
+	ProviderFrankfurter = "frankfurter"
+
+	frankfurterURL = "https://api.frankfurter.app"
+)
+
+var (
+	// ErrRateNotFound ...
+	ErrRateNotFound = errors.New("exchange rate not found")
+)
+
+// NewService ...
+func NewService(provider, file string) (Service, error) {
+	switch provider {
+	case ProviderFile:
+		return newFileService(file)
+	case "", ProviderFrankfurter:
+		return &frankfurterService{baseURL: frankfurterURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown exchange rates provider '%s'", provider)
+	}
+}
+
+func newFileService(file string) (*fileService, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading exchange rates file '%s': %w", file, err)
+	}
+	var conf struct {
+		Base  string            `yaml:"base"`
+		Rates map[string]string `yaml:"rates"`
+	}
+	if err := yaml.Unmarshal(b, &conf); err != nil {
+		return nil, fmt.Errorf("error unmarshaling exchange rates file: %w", err)
+	}
+	base, ok := models.ParseCurrency(conf.Base)
+	if !ok {
+		return nil, fmt.Errorf("invalid base currency '%s' in exchange rates file", conf.Base)
+	}
+	s := &fileService{base: base, rates: map[models.Currency]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, value := range conf.Rates {
+		currency, ok := models.ParseCurrency(code)
+		if !ok {
+			return nil, fmt.Errorf("invalid currency '%s' in exchange rates file", code)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate '%s' for currency '%s'", value, code)
+		}
+		s.rates[currency] = rate
+	}
+	return s, nil
+}
+
+func (s *fileService) Rate(ctx context.Context, from, to models.Currency) (*big.Rat, error) {
+	fromRate, ok := s.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateNotFound, from)
+	}
+	toRate, ok := s.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateNotFound, to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+func (s *frankfurterService) Rate(ctx context.Context, from, to models.Currency) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	q := url.Values{}
+	q.Set("from", string(from))
+	q.Set("to", string(to))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/latest?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating exchange rates request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting exchange rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rates request returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error unmarshaling exchange rates response: %w", err)
+	}
+	value, ok := body.Rates[string(to)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	rate, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return nil, fmt.Errorf("invalid exchange rate '%s' from %s to %s", value, from, to)
+	}
+	return rate, nil
+}
@@ -0,0 +1,114 @@
+package rates_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/rates"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileService(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rates.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`base: EUR
+rates:
+  GBP: "0.85"
+  BRL: "5.4"
+  JPY: "160"
+`), 0o600))
+	s, err := rates.NewService(rates.ProviderFile, file)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		from     models.Currency
+		to       models.Currency
+		expected *big.Rat
+		err      string
+	}{
+		{
+			name:     "from the base",
+			from:     "EUR",
+			to:       "GBP",
+			expected: big.NewRat(85, 100),
+		},
+		{
+			name:     "to the base",
+			from:     "GBP",
+			to:       "EUR",
+			expected: big.NewRat(100, 85),
+		},
+		{
+			name:     "cross rate through the base",
+			from:     "GBP",
+			to:       "BRL",
+			expected: big.NewRat(540, 85),
+		},
+		{
+			name:     "same currency",
+			from:     "JPY",
+			to:       "JPY",
+			expected: big.NewRat(1, 1),
+		},
+		{
+			name: "unknown from",
+			from: "USD",
+			to:   "EUR",
+			err:  "exchange rate not found: USD",
+		},
+		{
+			name: "unknown to",
+			from: "EUR",
+			to:   "CHF",
+			err:  "exchange rate not found: CHF",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := s.Rate(context.Background(), tt.from, tt.to)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.ErrorIs(t, err, rates.ErrRateNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected.RatString(), rate.RatString())
+		})
+	}
+}
+
+func TestNewFileServiceErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "invalid base",
+			content: "base: euro\n",
+			err:     "invalid base currency 'euro' in exchange rates file",
+		},
+		{
+			name:    "invalid currency",
+			content: "base: EUR\nrates:\n  pound: \"0.85\"\n",
+			err:     "invalid currency 'pound' in exchange rates file",
+		},
+		{
+			name:    "invalid rate",
+			content: "base: EUR\nrates:\n  GBP: \"-1\"\n",
+			err:     "invalid exchange rate '-1' for currency 'GBP'",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "rates.yml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.content), 0o600))
+			_, err := rates.NewService(rates.ProviderFile, file)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
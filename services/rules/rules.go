@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/objects"
+)
+
+type (
+	// Service persists the assignment rules added at runtime.
+	Service interface {
+		// Load returns the stored rules, none if they were never stored.
+		Load(ctx context.Context) (models.Rules, error)
+		// Store replaces the stored rules.
+		Store(ctx context.Context, rules models.Rules) error
+	}
+
+	service struct {
+		store objects.Store
+	}
+)
+
+const (
+	object = "rules.json"
+)
+
+// NewService ...
+func NewService(store objects.Store) Service {
+	return &service{store: store}
+}
+
+func (s *service) Load(ctx context.Context) (models.Rules, error) {
+	var rules models.Rules
+	if err := s.store.Load(ctx, object, &rules); err != nil {
+		if errors.Is(err, objects.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error loading rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *service) Store(ctx context.Context, rules models.Rules) error {
+	if err := s.store.Store(ctx, object, rules); err != nil {
+		return fmt.Errorf("error storing rules: %w", err)
+	}
+	return nil
+}
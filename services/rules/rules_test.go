@@ -0,0 +1,48 @@
+package rules_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/objects"
+	"github.com/matheuscscp/splitwiser/services/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	fileStore, err := objects.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name  string
+		store objects.Store
+	}{
+		{name: "file", store: fileStore},
+		{name: "memory", store: objects.NewMemoryStore()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := rules.NewService(tt.store)
+
+			stored, err := s.Load(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, stored)
+
+			expected := models.Rules{
+				{Pattern: "(?i)vegan|oat|tofu", Owner: "a"},
+				{Pattern: "deposit|bag fee", Owner: models.Shared},
+			}
+			require.NoError(t, s.Store(ctx, expected))
+			stored, err = s.Load(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, expected, stored)
+
+			require.NoError(t, s.Store(ctx, expected[1:]))
+			stored, err = s.Load(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, expected[1:], stored)
+		})
+	}
+}
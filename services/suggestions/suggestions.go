@@ -0,0 +1,139 @@
+package suggestions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matheuscscp/splitwiser/models"
+	"github.com/matheuscscp/splitwiser/services/objects"
+)
+
+type (
+	// Service learns the owners of items from the decisions of previous
+	// receipts.
+	Service interface {
+		// Record adds the final decisions of a receipt of the store.
+		Record(ctx context.Context, store string, decisions []Decision) error
+		// Suggest returns the suggested owner of each item name of a receipt
+		// of the store, nil for names without history.
+		Suggest(ctx context.Context, store string, names []string) ([]*Suggestion, error)
+	}
+
+	// Decision is the owner chosen for an item.
+	Decision struct {
+		Name  string
+		Owner models.ReceiptItemOwner
+	}
+
+	// Suggestion is the most frequent owner of an item.
+	Suggestion struct {
+		Owner models.ReceiptItemOwner
+		// Confidence is the fraction of the decisions choosing Owner, lower
+		// if the item was never bought in the store, between 0 and 1.
+		Confidence float64
+	}
+
+	// document is the number of times each owner was chosen for each key,
+	// see key.
+	document map[string]map[models.ReceiptItemOwner]int
+
+	service struct {
+		store objects.Store
+	}
+)
+
+const (
+	object = "owners.json"
+
+	// otherStoresFactor scales the confidence of suggestions learned from
+	// other stores.
+	otherStoresFactor = 0.8
+)
+
+// NewService ...
+func NewService(store objects.Store) Service {
+	return &service{store: store}
+}
+
+// key is the normalized store and item name. Decisions are also recorded
+// with an empty store, to suggest owners for items bought in other stores.
+func key(store, name string) string {
+	return normalize(store) + "\n" + normalize(name)
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func (d document) record(store string, decisions []Decision) {
+	add := func(k string, owner models.ReceiptItemOwner) {
+		if d[k] == nil {
+			d[k] = make(map[models.ReceiptItemOwner]int)
+		}
+		d[k][owner]++
+	}
+	for _, decision := range decisions {
+		if normalize(decision.Name) == "" || decision.Owner == "" {
+			continue
+		}
+		if normalize(store) != "" {
+			add(key(store, decision.Name), decision.Owner)
+		}
+		add(key("", decision.Name), decision.Owner)
+	}
+}
+
+func (d document) suggest(store string, names []string) []*Suggestion {
+	suggestions := make([]*Suggestion, len(names))
+	for i, name := range names {
+		if s := mostFrequent(d[key(store, name)]); s != nil && normalize(store) != "" {
+			suggestions[i] = s
+		} else if s := mostFrequent(d[key("", name)]); s != nil {
+			s.Confidence *= otherStoresFactor
+			suggestions[i] = s
+		}
+	}
+	return suggestions
+}
+
+// mostFrequent returns the owner chosen most times, breaking ties by code.
+func mostFrequent(counts map[models.ReceiptItemOwner]int) *Suggestion {
+	owners := make([]models.ReceiptItemOwner, 0, len(counts))
+	var total int
+	for owner, count := range counts {
+		owners = append(owners, owner)
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if counts[owners[i]] != counts[owners[j]] {
+			return counts[owners[i]] > counts[owners[j]]
+		}
+		return owners[i] < owners[j]
+	})
+	return &Suggestion{
+		Owner:      owners[0],
+		Confidence: float64(counts[owners[0]]) / float64(total),
+	}
+}
+
+func (s *service) Record(ctx context.Context, store string, decisions []Decision) error {
+	doc := make(document)
+	if err := objects.Update(ctx, s.store, object, &doc, func() { doc.record(store, decisions) }); err != nil {
+		return fmt.Errorf("error updating owner history: %w", err)
+	}
+	return nil
+}
+
+func (s *service) Suggest(ctx context.Context, store string, names []string) ([]*Suggestion, error) {
+	doc := make(document)
+	if err := s.store.Load(ctx, object, &doc); err != nil && !errors.Is(err, objects.ErrNotExist) {
+		return nil, fmt.Errorf("error loading owner history: %w", err)
+	}
+	return doc.suggest(store, names), nil
+}
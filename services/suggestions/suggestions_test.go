@@ -0,0 +1,54 @@
+package suggestions_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/services/objects"
+	"github.com/matheuscscp/splitwiser/services/suggestions"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	s := suggestions.NewService(objects.NewMemoryStore())
+
+	names := []string{"Oat Milk", "Whey Protein", "Bread"}
+	suggested, err := s.Suggest(ctx, "Tesco", names)
+	require.NoError(t, err)
+	assert.Equal(t, []*suggestions.Suggestion{nil, nil, nil}, suggested)
+
+	for _, decisions := range [][]suggestions.Decision{
+		{{Name: "Oat Milk", Owner: "a"}, {Name: "Whey Protein", Owner: "m"}},
+		{{Name: "oat  milk", Owner: "a"}, {Name: "Whey Protein", Owner: "m"}},
+		{{Name: "Oat Milk", Owner: "s"}, {Name: "Whey Protein", Owner: "m"}},
+	} {
+		require.NoError(t, s.Record(ctx, "Tesco", decisions))
+	}
+	require.NoError(t, s.Record(ctx, "Lidl", []suggestions.Decision{{Name: "Bread", Owner: "s"}, {Name: "", Owner: "a"}}))
+
+	suggested, err = s.Suggest(ctx, " tesco ", names)
+	require.NoError(t, err)
+	require.Len(t, suggested, 3)
+	assert.Equal(t, "a", string(suggested[0].Owner))
+	assert.InDelta(t, 2.0/3, suggested[0].Confidence, 1e-9)
+	assert.Equal(t, &suggestions.Suggestion{Owner: "m", Confidence: 1}, suggested[1])
+	assert.Equal(t, "s", string(suggested[2].Owner))
+	assert.InDelta(t, 0.8, suggested[2].Confidence, 1e-9, "learned in another store")
+
+	suggested, err = s.Suggest(ctx, "", []string{"Whey Protein", "Apples"})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.8, suggested[0].Confidence, 1e-9)
+	assert.Nil(t, suggested[1])
+}
+
+func TestDisabledService(t *testing.T) {
+	ctx := context.Background()
+	s := suggestions.NewService(objects.Disabled)
+	require.NoError(t, s.Record(ctx, "Tesco", []suggestions.Decision{{Name: "Bread", Owner: "s"}}))
+	suggested, err := s.Suggest(ctx, "Tesco", []string{"Bread"})
+	require.NoError(t, err)
+	assert.Equal(t, []*suggestions.Suggestion{nil}, suggested)
+}
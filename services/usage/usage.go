@@ -0,0 +1,128 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/matheuscscp/splitwiser/services/objects"
+)
+
+type (
+	// Service persists the monthly totals of the tokens used by models.
+	Service interface {
+		// Add adds the totals to the ones of the month, like "2024-03".
+		Add(ctx context.Context, month string, totals Totals) error
+		// Months returns the monthly totals, most recent first.
+		Months(ctx context.Context) ([]Month, error)
+	}
+
+	// Totals are the tokens used by requests to models and their cost.
+	Totals struct {
+		Requests         int `json:"requests"`
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		// Cost is in millionths of the currency of the prices.
+		Cost int64 `json:"cost"`
+	}
+
+	// Month ...
+	Month struct {
+		Month string
+		Totals
+	}
+
+	// Prices are the prices of models by name.
+	Prices map[string]Price
+
+	// Price is the price of a million tokens of a model.
+	Price struct {
+		Prompt     float64
+		Completion float64
+	}
+
+	// document is the persisted monthly totals by month.
+	document map[string]Totals
+
+	service struct {
+		store objects.Store
+	}
+)
+
+const (
+	object = "usage.json"
+)
+
+var (
+	// DefaultPrices are the prices in USD of the default models.
+	DefaultPrices = Prices{
+		"gpt-4o":      {Prompt: 2.5, Completion: 10},
+		"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+	}
+)
+
+// NewService ...
+func NewService(store objects.Store) Service {
+	return &service{store: store}
+}
+
+// Cost returns the totals of a request to the model with the given tokens,
+// and false if the price of the model is unknown.
+func (p Prices) Cost(model string, promptTokens, completionTokens int) (Totals, bool) {
+	totals := Totals{Requests: 1, PromptTokens: promptTokens, CompletionTokens: completionTokens}
+	price, ok := p[model]
+	if !ok {
+		return totals, false
+	}
+	// the price of a million tokens in units is the price of a token in
+	// millionths
+	cost := float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion
+	totals.Cost = int64(math.Round(cost))
+	return totals, true
+}
+
+// Add ...
+func (t *Totals) Add(other Totals) {
+	t.Requests += other.Requests
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.Cost += other.Cost
+}
+
+// FormatCost formats a cost in millionths as units with four decimal places.
+func FormatCost(cost int64) string {
+	return fmt.Sprintf("%.4f", float64(cost)/1e6)
+}
+
+// ParseCost converts an amount in units to millionths.
+func ParseCost(amount float64) int64 {
+	return int64(math.Round(amount * 1e6))
+}
+
+func (s *service) Add(ctx context.Context, month string, totals Totals) error {
+	doc := make(document)
+	err := objects.Update(ctx, s.store, object, &doc, func() {
+		t := doc[month]
+		t.Add(totals)
+		doc[month] = t
+	})
+	if err != nil {
+		return fmt.Errorf("error updating usage: %w", err)
+	}
+	return nil
+}
+
+func (s *service) Months(ctx context.Context) ([]Month, error) {
+	doc := make(document)
+	if err := s.store.Load(ctx, object, &doc); err != nil && !errors.Is(err, objects.ErrNotExist) {
+		return nil, fmt.Errorf("error loading usage: %w", err)
+	}
+	months := make([]Month, 0, len(doc))
+	for month, totals := range doc {
+		months = append(months, Month{Month: month, Totals: totals})
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Month > months[j].Month })
+	return months, nil
+}
@@ -0,0 +1,84 @@
+package usage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matheuscscp/splitwiser/services/objects"
+	"github.com/matheuscscp/splitwiser/services/usage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCost(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		model    string
+		prompt   int
+		complete int
+		expected usage.Totals
+		ok       bool
+	}{
+		{
+			name:     "known model",
+			model:    "gpt-4o",
+			prompt:   1200,
+			complete: 300,
+			expected: usage.Totals{Requests: 1, PromptTokens: 1200, CompletionTokens: 300, Cost: 6000},
+			ok:       true,
+		},
+		{
+			name:     "rounding",
+			model:    "gpt-4o-mini",
+			prompt:   3,
+			complete: 1,
+			expected: usage.Totals{Requests: 1, PromptTokens: 3, CompletionTokens: 1, Cost: 1},
+			ok:       true,
+		},
+		{
+			name:     "unknown model",
+			model:    "llava",
+			prompt:   1200,
+			complete: 300,
+			expected: usage.Totals{Requests: 1, PromptTokens: 1200, CompletionTokens: 300},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			totals, ok := usage.DefaultPrices.Cost(tt.model, tt.prompt, tt.complete)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, totals)
+		})
+	}
+	assert.Equal(t, "0.0060", usage.FormatCost(6000))
+	assert.Equal(t, int64(2500000), usage.ParseCost(2.5))
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	s := usage.NewService(objects.NewMemoryStore())
+
+	months, err := s.Months(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, months)
+
+	require.NoError(t, s.Add(ctx, "2024-02", usage.Totals{Requests: 1, PromptTokens: 100, CompletionTokens: 10, Cost: 350}))
+	require.NoError(t, s.Add(ctx, "2024-03", usage.Totals{Requests: 1, PromptTokens: 200, CompletionTokens: 20, Cost: 700}))
+	require.NoError(t, s.Add(ctx, "2024-03", usage.Totals{Requests: 2, PromptTokens: 300, CompletionTokens: 30, Cost: 1050}))
+
+	months, err = s.Months(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []usage.Month{
+		{Month: "2024-03", Totals: usage.Totals{Requests: 3, PromptTokens: 500, CompletionTokens: 50, Cost: 1750}},
+		{Month: "2024-02", Totals: usage.Totals{Requests: 1, PromptTokens: 100, CompletionTokens: 10, Cost: 350}},
+	}, months)
+}
+
+func TestDisabledService(t *testing.T) {
+	ctx := context.Background()
+	s := usage.NewService(objects.Disabled)
+	require.NoError(t, s.Add(ctx, "2024-03", usage.Totals{Requests: 1}))
+	months, err := s.Months(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, months)
+}